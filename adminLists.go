@@ -0,0 +1,94 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"strings"
+)
+
+// GetManagers calls qconf -sm and returns the list of managers.
+func GetManagers() ([]string, error) {
+	return qconfList("-sm")
+}
+
+// AddManagers adds the users to the list of managers (qconf -am).
+func AddManagers(users ...string) error {
+	_, err := qconf("-am", strings.Join(users, ","))
+	return err
+}
+
+// DeleteManagers removes the users from the list of managers (qconf -dm).
+func DeleteManagers(users ...string) error {
+	_, err := qconf("-dm", strings.Join(users, ","))
+	return err
+}
+
+// GetOperators calls qconf -so and returns the list of operators.
+func GetOperators() ([]string, error) {
+	return qconfList("-so")
+}
+
+// AddOperators adds the users to the list of operators (qconf -ao).
+func AddOperators(users ...string) error {
+	_, err := qconf("-ao", strings.Join(users, ","))
+	return err
+}
+
+// DeleteOperators removes the users from the list of operators
+// (qconf -do).
+func DeleteOperators(users ...string) error {
+	_, err := qconf("-do", strings.Join(users, ","))
+	return err
+}
+
+// GetAdminHosts calls qconf -sh and returns the list of administrative
+// hosts.
+func GetAdminHosts() ([]string, error) {
+	return qconfList("-sh")
+}
+
+// AddAdminHosts adds the hosts to the list of administrative hosts
+// (qconf -ah).
+func AddAdminHosts(hosts ...string) error {
+	_, err := qconf("-ah", strings.Join(hosts, ","))
+	return err
+}
+
+// DeleteAdminHosts removes the hosts from the list of administrative
+// hosts (qconf -dh).
+func DeleteAdminHosts(hosts ...string) error {
+	_, err := qconf("-dh", strings.Join(hosts, ","))
+	return err
+}
+
+// GetSubmitHosts calls qconf -ss and returns the list of submit hosts.
+func GetSubmitHosts() ([]string, error) {
+	return qconfList("-ss")
+}
+
+// AddSubmitHosts adds the hosts to the list of submit hosts (qconf -as).
+func AddSubmitHosts(hosts ...string) error {
+	_, err := qconf("-as", strings.Join(hosts, ","))
+	return err
+}
+
+// DeleteSubmitHosts removes the hosts from the list of submit hosts
+// (qconf -ds).
+func DeleteSubmitHosts(hosts ...string) error {
+	_, err := qconf("-ds", strings.Join(hosts, ","))
+	return err
+}
@@ -0,0 +1,484 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarState is the state a calendar puts a queue into.
+type CalendarState int
+
+const (
+	// CalendarOn means the queue is enabled
+	CalendarOn CalendarState = iota
+	// CalendarOff means the queue is disabled
+	CalendarOff
+	// CalendarSuspended means the queue is suspended
+	CalendarSuspended
+)
+
+// String returns the calendar_conf representation of the state.
+func (s CalendarState) String() string {
+	switch s {
+	case CalendarOn:
+		return "on"
+	case CalendarOff:
+		return "off"
+	case CalendarSuspended:
+		return "suspended"
+	}
+	return ""
+}
+
+// YearDayRange is a range of dates (dd.mm.yyyy-dd.mm.yyyy) including
+// the start and the end date. For a single date Start and End are equal.
+type YearDayRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// WeekDayRange is a range of week days like mon-fri. Ranges can wrap
+// around the end of the week (fri-mon).
+type WeekDayRange struct {
+	Start time.Weekday
+	End   time.Weekday
+}
+
+// DaytimeRange is a range of the day like 6-20 or 8:30-17:00, given as
+// offsets since midnight. When Start is after End the range wraps
+// around midnight, i.e. it covers the time before End and after Start.
+type DaytimeRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// CalendarEntry is one entry of the year or week specification of a
+// calendar like 12.03.2004=12-11=off or mon-fri=6-20=suspended. When no
+// days are given the entry applies to all days, when no daytimes are
+// given it applies to the whole day.
+type CalendarEntry struct {
+	YearDays []YearDayRange
+	WeekDays []WeekDayRange
+	Daytimes []DaytimeRange
+	State    CalendarState
+}
+
+// Calendar is a Univa Grid Engine calendar as shown by qconf -scal.
+// Year entries take precedence over week entries. At times which are
+// not covered by any entry the queue is enabled.
+type Calendar struct {
+	Name string
+	Year []CalendarEntry
+	Week []CalendarEntry
+}
+
+var weekDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+const dateLayout = "2.1.2006"
+
+// ParseCalendar parses a calendar in the format of qconf -scal:
+// calendar_name    night
+// year             12.03.2004=12-11=off
+// week             mon-fri=6-20=suspended
+func ParseCalendar(cal string) (*Calendar, error) {
+	attrs, err := parseAttributes(cal)
+	if err != nil {
+		return nil, err
+	}
+	var c Calendar
+	for _, a := range attrs {
+		switch a.name {
+		case "calendar_name":
+			c.Name = a.value
+		case "year":
+			c.Year, err = parseCalendarEntries(a.value, true)
+		case "week":
+			c.Week, err = parseCalendarEntries(a.value, false)
+		default:
+			err = fmt.Errorf("unknown calendar attribute %s", a.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Name == "" {
+		return nil, errors.New("calendar has no calendar_name")
+	}
+	return &c, nil
+}
+
+// parseCalendarEntries parses the white space separated entries of a
+// year or week specification.
+func parseCalendarEntries(spec string, year bool) ([]CalendarEntry, error) {
+	if strings.EqualFold(spec, "NONE") {
+		return nil, nil
+	}
+	var entries []CalendarEntry
+	for _, e := range strings.Fields(spec) {
+		entry, err := parseCalendarEntry(e, year)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseCalendarEntry(spec string, year bool) (CalendarEntry, error) {
+	entry := CalendarEntry{State: CalendarOff}
+	parts := strings.Split(spec, "=")
+	if len(parts) > 3 {
+		return entry, fmt.Errorf("calendar entry %s has too many parts", spec)
+	}
+	if state, ok := parseCalendarState(parts[len(parts)-1]); ok {
+		entry.State = state
+		parts = parts[:len(parts)-1]
+	}
+	for i, p := range parts {
+		var err error
+		switch {
+		case i == 0 && year && strings.Contains(p, "."):
+			entry.YearDays, err = parseYearDays(p)
+		case i == 0 && !year && strings.IndexAny(p, "0123456789") < 0:
+			entry.WeekDays, err = parseWeekDays(p)
+		case entry.Daytimes == nil:
+			entry.Daytimes, err = parseDaytimes(p)
+		default:
+			err = errors.New("unexpected part " + p)
+		}
+		if err != nil {
+			return entry, fmt.Errorf("calendar entry %s: %s", spec, err)
+		}
+	}
+	return entry, nil
+}
+
+func parseCalendarState(s string) (CalendarState, bool) {
+	switch s {
+	case "on":
+		return CalendarOn, true
+	case "off":
+		return CalendarOff, true
+	case "suspended":
+		return CalendarSuspended, true
+	}
+	return CalendarOn, false
+}
+
+func parseYearDays(list string) ([]YearDayRange, error) {
+	var ranges []YearDayRange
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.Split(r, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("wrong year day range %s", r)
+		}
+		start, err := time.Parse(dateLayout, bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("wrong year day %s", bounds[0])
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = time.Parse(dateLayout, bounds[1]); err != nil {
+				return nil, fmt.Errorf("wrong year day %s", bounds[1])
+			}
+		}
+		ranges = append(ranges, YearDayRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+func parseWeekDays(list string) ([]WeekDayRange, error) {
+	var ranges []WeekDayRange
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.Split(r, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("wrong week day range %s", r)
+		}
+		var days []time.Weekday
+		for _, b := range bounds {
+			found := false
+			for d, name := range weekDays {
+				if strings.EqualFold(b, name) {
+					days = append(days, time.Weekday(d))
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("wrong week day %s", b)
+			}
+		}
+		if len(days) == 1 {
+			days = append(days, days[0])
+		}
+		ranges = append(ranges, WeekDayRange{Start: days[0], End: days[1]})
+	}
+	return ranges, nil
+}
+
+func parseDaytimes(list string) ([]DaytimeRange, error) {
+	var ranges []DaytimeRange
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.Split(r, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("wrong daytime range %s", r)
+		}
+		var times [2]time.Duration
+		for i, b := range bounds {
+			parts := strings.Split(b, ":")
+			if len(parts) > 3 {
+				return nil, fmt.Errorf("wrong daytime %s", b)
+			}
+			var d time.Duration
+			units := []time.Duration{time.Hour, time.Minute, time.Second}
+			for j, p := range parts {
+				n, err := strconv.Atoi(p)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("wrong daytime %s", b)
+				}
+				d += time.Duration(n) * units[j]
+			}
+			if d > 24*time.Hour {
+				return nil, fmt.Errorf("daytime %s is after 24", b)
+			}
+			times[i] = d
+		}
+		ranges = append(ranges, DaytimeRange{Start: times[0], End: times[1]})
+	}
+	return ranges, nil
+}
+
+// String returns the calendar in the qconf file format.
+func (c Calendar) String() string {
+	return formatAttributes([]attribute{
+		{"calendar_name", c.Name},
+		{"year", formatCalendarEntries(c.Year)},
+		{"week", formatCalendarEntries(c.Week)},
+	})
+}
+
+func formatCalendarEntries(entries []CalendarEntry) string {
+	if len(entries) == 0 {
+		return "NONE"
+	}
+	specs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		specs = append(specs, e.String())
+	}
+	return strings.Join(specs, " ")
+}
+
+// String returns the entry in calendar_conf format.
+func (e CalendarEntry) String() string {
+	var parts, list []string
+	for _, d := range e.YearDays {
+		r := d.Start.Format("02.01.2006")
+		if !d.End.Equal(d.Start) {
+			r += "-" + d.End.Format("02.01.2006")
+		}
+		list = append(list, r)
+	}
+	for _, d := range e.WeekDays {
+		r := weekDays[d.Start]
+		if d.End != d.Start {
+			r += "-" + weekDays[d.End]
+		}
+		list = append(list, r)
+	}
+	if len(list) > 0 {
+		parts = append(parts, strings.Join(list, ","))
+	}
+	list = nil
+	for _, d := range e.Daytimes {
+		list = append(list, formatDaytime(d.Start)+"-"+formatDaytime(d.End))
+	}
+	if len(list) > 0 {
+		parts = append(parts, strings.Join(list, ","))
+	}
+	parts = append(parts, e.State.String())
+	return strings.Join(parts, "=")
+}
+
+func formatDaytime(d time.Duration) string {
+	h, m, s := int(d/time.Hour), int(d/time.Minute%60), int(d/time.Second%60)
+	switch {
+	case s != 0:
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	case m != 0:
+		return fmt.Sprintf("%d:%02d", h, m)
+	}
+	return strconv.Itoa(h)
+}
+
+// matches returns true if the entry covers the time.
+func (e CalendarEntry) matches(t time.Time) bool {
+	if len(e.YearDays) > 0 {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		found := false
+		for _, d := range e.YearDays {
+			if !day.Before(d.Start) && !day.After(d.End) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(e.WeekDays) > 0 {
+		found := false
+		for _, d := range e.WeekDays {
+			wd := t.Weekday()
+			if (d.Start <= d.End && wd >= d.Start && wd <= d.End) ||
+				(d.Start > d.End && (wd >= d.Start || wd <= d.End)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(e.Daytimes) == 0 {
+		return true
+	}
+	// the wall clock time, on DST days it differs from the time elapsed
+	// since midnight
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	for _, d := range e.Daytimes {
+		if d.Start <= d.End && offset >= d.Start && offset < d.End {
+			return true
+		}
+		if d.Start > d.End && (offset >= d.Start || offset < d.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// StateAt returns the state the calendar defines for the given time.
+// The time is interpreted in its location.
+func (c Calendar) StateAt(t time.Time) CalendarState {
+	for _, e := range c.Year {
+		if e.matches(t) {
+			return e.State
+		}
+	}
+	for _, e := range c.Week {
+		if e.matches(t) {
+			return e.State
+		}
+	}
+	return CalendarOn
+}
+
+// NextTransition returns the first time after t at which the state of
+// the calendar changes and the new state. When the state does not
+// change anymore false is returned.
+func (c Calendar) NextTransition(t time.Time) (time.Time, CalendarState, bool) {
+	current := c.StateAt(t)
+	// beyond the last year day and one week later all days repeat
+	last := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).AddDate(0, 0, 8)
+	for _, e := range c.Year {
+		for _, d := range e.YearDays {
+			end := time.Date(d.End.Year(), d.End.Month(), d.End.Day(), 0, 0, 0, 0, t.Location()).AddDate(0, 0, 8)
+			if end.After(last) {
+				last = end
+			}
+		}
+	}
+	for day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()); day.Before(last); day = day.AddDate(0, 0, 1) {
+		for _, b := range c.boundaries(day) {
+			if !b.After(t) {
+				continue
+			}
+			if s := c.StateAt(b); s != current {
+				return b, s, true
+			}
+		}
+	}
+	return time.Time{}, current, false
+}
+
+// boundaries returns the sorted points in time of the given day at
+// which the state of the calendar can change.
+func (c Calendar) boundaries(day time.Time) []time.Time {
+	offsets := map[time.Duration]bool{0: true}
+	for _, entries := range [][]CalendarEntry{c.Year, c.Week} {
+		for _, e := range entries {
+			for _, d := range e.Daytimes {
+				offsets[d.Start] = true
+				offsets[d.End] = true
+			}
+		}
+	}
+	var times []time.Time
+	for o := range offsets {
+		if o < 24*time.Hour {
+			h, m, s := int(o/time.Hour), int(o/time.Minute%60), int(o/time.Second%60)
+			times = append(times, time.Date(day.Year(), day.Month(), day.Day(), h, m, s, 0, day.Location()))
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// GetCalendarList calls qconf -scall and returns the names of all
+// calendars.
+func GetCalendarList() ([]string, error) {
+	return qconfList("-scall")
+}
+
+// GetCalendars calls qconf -scal <name> for each given calendar and
+// parses the output into Calendar structs.
+func GetCalendars(names ...string) ([]Calendar, error) {
+	cals := make([]Calendar, 0, len(names))
+	for _, name := range names {
+		out, err := qconf("-scal", name)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ParseCalendar(string(out))
+		if err != nil {
+			return nil, err
+		}
+		cals = append(cals, *c)
+	}
+	return cals, nil
+}
+
+// AddCalendar creates the calendar in the cluster (qconf -Acal).
+func AddCalendar(c Calendar) error {
+	return qconfFromFile("-Acal", c.String())
+}
+
+// ModifyCalendar replaces an existing calendar (qconf -Mcal).
+func ModifyCalendar(c Calendar) error {
+	return qconfFromFile("-Mcal", c.String())
+}
+
+// DeleteCalendars removes the given calendars (qconf -dcal).
+func DeleteCalendars(names ...string) error {
+	_, err := qconf("-dcal", strings.Join(names, ","))
+	return err
+}
@@ -0,0 +1,150 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+	"time"
+)
+
+var testCalendar = `calendar_name    night
+year             12.03.2004=12-11=off 24.12.2015-26.12.2015
+week             mon-fri=6-20=suspended sat-sun=6:30-7:00=off`
+
+func TestParseCalendar(t *testing.T) {
+	c, err := ParseCalendar(testCalendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "night" || len(c.Year) != 2 || len(c.Week) != 2 {
+		t.Fatalf("Wrong calendar: %v", c)
+	}
+	y := c.Year[0]
+	if y.State != CalendarOff || len(y.Daytimes) != 1 || y.Daytimes[0].Start != 12*time.Hour {
+		t.Errorf("Wrong first year entry: %s", y)
+	}
+	if c.Year[1].State != CalendarOff || c.Year[1].YearDays[0].End.Day() != 26 {
+		t.Errorf("Wrong second year entry: %s", c.Year[1])
+	}
+	w := c.Week[0]
+	if w.State != CalendarSuspended || w.WeekDays[0].Start != time.Monday || w.WeekDays[0].End != time.Friday {
+		t.Errorf("Wrong week entry: %s", w)
+	}
+	if c.Week[1].Daytimes[0].Start != 6*time.Hour+30*time.Minute {
+		t.Errorf("Wrong week entry: %s", c.Week[1])
+	}
+	again, err := ParseCalendar(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != c.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", c, again)
+	}
+	if _, err := ParseCalendar("calendar_name x\nweek moo-fri=off"); err == nil {
+		t.Error("Expected error for unknown week day")
+	}
+}
+
+func TestCalendarStateAt(t *testing.T) {
+	c, err := ParseCalendar(testCalendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		time  time.Time
+		state CalendarState
+	}{
+		// Monday
+		{time.Date(2015, 11, 23, 5, 0, 0, 0, time.UTC), CalendarOn},
+		{time.Date(2015, 11, 23, 6, 0, 0, 0, time.UTC), CalendarSuspended},
+		{time.Date(2015, 11, 23, 20, 0, 0, 0, time.UTC), CalendarOn},
+		// Sunday
+		{time.Date(2015, 11, 22, 6, 45, 0, 0, time.UTC), CalendarOff},
+		{time.Date(2015, 11, 22, 10, 0, 0, 0, time.UTC), CalendarOn},
+		// Christmas (Friday) is completely off
+		{time.Date(2015, 12, 25, 23, 0, 0, 0, time.UTC), CalendarOff},
+		// 12.03.2004 (Friday) is off except between 11 and 12
+		{time.Date(2004, 3, 12, 11, 30, 0, 0, time.UTC), CalendarSuspended},
+		{time.Date(2004, 3, 12, 12, 30, 0, 0, time.UTC), CalendarOff},
+	}
+	for _, test := range tests {
+		if s := c.StateAt(test.time); s != test.state {
+			t.Errorf("Expected %s at %s but got %s", test.state, test.time, s)
+		}
+	}
+}
+
+func TestCalendarNextTransition(t *testing.T) {
+	c, err := ParseCalendar(testCalendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Friday evening: suspended until 20:00
+	next, state, ok := c.NextTransition(time.Date(2015, 11, 20, 19, 0, 0, 0, time.UTC))
+	if !ok || state != CalendarOn || !next.Equal(time.Date(2015, 11, 20, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong transition: %s %s %v", next, state, ok)
+	}
+	// Christmas: reopens on 27.12. at midnight (Sunday)
+	next, state, ok = c.NextTransition(time.Date(2015, 12, 24, 1, 0, 0, 0, time.UTC))
+	if !ok || state != CalendarOn || !next.Equal(time.Date(2015, 12, 27, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong transition: %s %s %v", next, state, ok)
+	}
+	always := Calendar{Name: "always", Week: []CalendarEntry{{State: CalendarOff}}}
+	if _, _, ok := always.NextTransition(time.Now()); ok {
+		t.Error("Calendar which is always off has no transition")
+	}
+}
+
+func TestCalendarDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	c, err := ParseCalendar("calendar_name day\nyear NONE\nweek mon-sun=6-20=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 29.03.2026 switches to summer time, 25.10.2026 back to winter time
+	for _, date := range []struct {
+		month time.Month
+		day   int
+	}{{time.March, 29}, {time.October, 25}} {
+		month, day := date.month, date.day
+		for _, test := range []struct {
+			hour, minute int
+			state        CalendarState
+		}{
+			{5, 30, CalendarOn},
+			{6, 30, CalendarOff},
+			{19, 59, CalendarOff},
+			{20, 0, CalendarOn},
+		} {
+			at := time.Date(2026, month, day, test.hour, test.minute, 0, 0, berlin)
+			if s := c.StateAt(at); s != test.state {
+				t.Errorf("Expected %s at %s but got %s", test.state, at, s)
+			}
+		}
+		next, s, changes := c.NextTransition(time.Date(2026, month, day, 0, 0, 0, 0, berlin))
+		if !changes || s != CalendarOff || next.Hour() != 6 || next.Minute() != 0 || next.Day() != day {
+			t.Errorf("Wrong transition on %d.%d.: %s %s", day, month, next, s)
+		}
+		next, s, _ = c.NextTransition(next)
+		if s != CalendarOn || next.Hour() != 20 || next.Day() != day {
+			t.Errorf("Wrong transition on %d.%d.: %s %s", day, month, next, s)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+)
+
+// Checkpoint is a Univa Grid Engine checkpointing environment as shown
+// by qconf -sckpt. Attributes which are not part of the struct are kept
+// in Other.
+type Checkpoint struct {
+	Name           string
+	Interface      string
+	CkptCommand    string
+	MigrCommand    string
+	RestartCommand string
+	CleanCommand   string
+	CkptDir        string
+	Signal         string
+	When           string
+	Other          map[string]string
+}
+
+// fields binds the attribute names of qconf -sckpt to the struct fields.
+func (c *Checkpoint) fields() []configField {
+	return []configField{
+		{"ckpt_name", &c.Name},
+		{"interface", &c.Interface},
+		{"ckpt_command", &c.CkptCommand},
+		{"migr_command", &c.MigrCommand},
+		{"restart_command", &c.RestartCommand},
+		{"clean_command", &c.CleanCommand},
+		{"ckpt_dir", &c.CkptDir},
+		{"signal", &c.Signal},
+		{"when", &c.When},
+	}
+}
+
+// ParseCheckpoint parses a checkpointing environment in the format of
+// qconf -sckpt.
+func ParseCheckpoint(ckpt string) (*Checkpoint, error) {
+	attrs, err := parseAttributes(ckpt)
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	if c.Other, err = decodeFields(attrs, c.fields()); err != nil {
+		return nil, err
+	}
+	if c.Name == "" {
+		return nil, errors.New("checkpointing environment has no ckpt_name")
+	}
+	return &c, nil
+}
+
+// String returns the checkpointing environment in the qconf file format.
+func (c Checkpoint) String() string {
+	return formatAttributes(encodeFields(c.fields(), c.Other))
+}
+
+// GetCheckpointList calls qconf -sckptl and returns the names of all
+// checkpointing environments.
+func GetCheckpointList() ([]string, error) {
+	return qconfList("-sckptl")
+}
+
+// GetCheckpoints calls qconf -sckpt <name> for each given checkpointing
+// environment and parses the output into Checkpoint structs.
+func GetCheckpoints(names ...string) ([]Checkpoint, error) {
+	ckpts := make([]Checkpoint, 0, len(names))
+	for _, name := range names {
+		out, err := qconf("-sckpt", name)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ParseCheckpoint(string(out))
+		if err != nil {
+			return nil, err
+		}
+		ckpts = append(ckpts, *c)
+	}
+	return ckpts, nil
+}
+
+// AddCheckpoint creates the checkpointing environment (qconf -Ackpt).
+func AddCheckpoint(c Checkpoint) error {
+	return qconfFromFile("-Ackpt", c.String())
+}
+
+// ModifyCheckpoint replaces an existing checkpointing environment
+// (qconf -Mckpt).
+func ModifyCheckpoint(c Checkpoint) error {
+	return qconfFromFile("-Mckpt", c.String())
+}
+
+// DeleteCheckpoints removes the given checkpointing environments
+// (qconf -dckpt).
+func DeleteCheckpoints(names ...string) error {
+	for _, name := range names {
+		if _, err := qconf("-dckpt", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
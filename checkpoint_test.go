@@ -0,0 +1,53 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+)
+
+func TestParseCheckpoint(t *testing.T) {
+	ckpt := `ckpt_name          BLCR
+interface          APPLICATION-LEVEL
+ckpt_command       /opt/blcr/checkpoint $job_id
+migr_command       /opt/blcr/migrate $job_id
+restart_command    none
+clean_command      none
+ckpt_dir           /tmp
+signal             none
+when               sx`
+	c, err := ParseCheckpoint(ckpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "BLCR" || c.Interface != "APPLICATION-LEVEL" {
+		t.Errorf("Wrong checkpointing environment: %v", c)
+	}
+	if c.CkptCommand != "/opt/blcr/checkpoint $job_id" || c.When != "sx" {
+		t.Errorf("Wrong values: %s %s", c.CkptCommand, c.When)
+	}
+	again, err := ParseCheckpoint(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != c.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", c, again)
+	}
+	if _, err := ParseCheckpoint("interface USERDEFINED"); err == nil {
+		t.Error("Expected error for missing ckpt_name")
+	}
+}
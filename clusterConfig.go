@@ -0,0 +1,265 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// GlobalConfig is the name of the global cluster configuration.
+const GlobalConfig = "global"
+
+// ClusterConfig is the global or a host local Univa Grid Engine cluster
+// configuration as shown by qconf -sconf. The *_params attributes are
+// decoded into key/value maps. Attributes which are not part of the
+// struct are kept in Other.
+//
+// A host local configuration only contains the attributes which
+// override the global configuration. For configurations returned by
+// ParseClusterConfig only the parsed attributes and the attributes set
+// afterwards (non-zero fields and Other) are written by String and taken
+// into account by MergeClusterConfig, for configurations created
+// otherwise all attributes are.
+type ClusterConfig struct {
+	Name              string
+	ExecdSpoolDir     string
+	Mailer            string
+	Xterm             string
+	LoadSensor        string
+	Prolog            string
+	Epilog            string
+	ShellStartMode    string
+	LoginShells       string
+	LoadReportTime    time.Duration
+	MaxUnheard        time.Duration
+	RescheduleUnknown time.Duration
+	Loglevel          string
+	AdministratorMail string
+	MaxUJobs          int
+	MaxJobs           int
+	MaxAJInstances    int
+	MaxAJTasks        int
+	GidRange          string
+	QmasterParams     map[string]string
+	ExecdParams       map[string]string
+	ReportingParams   map[string]string
+	Other             map[string]string
+	// present contains the names of the parsed attributes
+	present map[string]bool
+}
+
+// fields binds the attribute names of qconf -sconf to the struct fields.
+func (cc *ClusterConfig) fields() []configField {
+	return []configField{
+		{"execd_spool_dir", &cc.ExecdSpoolDir},
+		{"mailer", &cc.Mailer},
+		{"xterm", &cc.Xterm},
+		{"load_sensor", &cc.LoadSensor},
+		{"prolog", &cc.Prolog},
+		{"epilog", &cc.Epilog},
+		{"shell_start_mode", &cc.ShellStartMode},
+		{"login_shells", &cc.LoginShells},
+		{"load_report_time", &cc.LoadReportTime},
+		{"max_unheard", &cc.MaxUnheard},
+		{"reschedule_unknown", &cc.RescheduleUnknown},
+		{"loglevel", &cc.Loglevel},
+		{"administrator_mail", &cc.AdministratorMail},
+		{"max_u_jobs", &cc.MaxUJobs},
+		{"max_jobs", &cc.MaxJobs},
+		{"max_aj_instances", &cc.MaxAJInstances},
+		{"max_aj_tasks", &cc.MaxAJTasks},
+		{"gid_range", &cc.GidRange},
+		{"qmaster_params", &cc.QmasterParams},
+		{"execd_params", &cc.ExecdParams},
+		{"reporting_params", &cc.ReportingParams},
+	}
+}
+
+// ParseClusterConfig parses a cluster configuration in the format of
+// qconf -sconf. The name is taken from the leading "#global:" or
+// "#<host>:" comment when there is one.
+func ParseClusterConfig(conf string) (*ClusterConfig, error) {
+	attrs, err := parseAttributes(conf)
+	if err != nil {
+		return nil, err
+	}
+	var cc ClusterConfig
+	first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(conf), "\n", 2)[0])
+	if strings.HasPrefix(first, "#") && strings.HasSuffix(first, ":") {
+		cc.Name = strings.TrimSuffix(strings.TrimPrefix(first, "#"), ":")
+	}
+	if cc.Other, err = decodeFields(attrs, cc.fields()); err != nil {
+		return nil, err
+	}
+	cc.present = make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		cc.present[a.name] = true
+	}
+	return &cc, nil
+}
+
+// attributes returns the attributes of the configuration which are
+// present: the parsed attributes, fields which were set afterwards
+// (non-zero values), and the Other attributes.
+func (cc ClusterConfig) attributes() []attribute {
+	all := encodeFields(cc.fields(), cc.Other)
+	if cc.present == nil {
+		return all
+	}
+	set := make(map[string]bool, len(cc.Other))
+	for _, f := range cc.fields() {
+		if !reflect.ValueOf(f.value).Elem().IsZero() {
+			set[f.name] = true
+		}
+	}
+	for name := range cc.Other {
+		set[name] = true
+	}
+	attrs := make([]attribute, 0, len(cc.present))
+	for _, a := range all {
+		if cc.present[a.name] || set[a.name] {
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+// String returns the configuration in the qconf file format.
+func (cc ClusterConfig) String() string {
+	return formatAttributes(cc.attributes())
+}
+
+// MergeClusterConfig returns the effective configuration of a host by
+// replacing the attributes of the global configuration with the ones
+// which are set in the local configuration of the host. Like in Grid
+// Engine attributes are replaced as a whole, i.e. execd_params of the
+// local configuration replace all execd_params of the global one.
+func MergeClusterConfig(global, local ClusterConfig) (*ClusterConfig, error) {
+	merged := global.attributes()
+	index := make(map[string]int, len(merged))
+	for i, a := range merged {
+		index[a.name] = i
+	}
+	for _, a := range local.attributes() {
+		if i, exists := index[a.name]; exists {
+			merged[i] = a
+		} else {
+			merged = append(merged, a)
+		}
+	}
+	cc, err := ParseClusterConfig(formatAttributes(merged))
+	if err != nil {
+		return nil, err
+	}
+	cc.Name = local.Name
+	return cc, nil
+}
+
+// GetClusterConfigList calls qconf -sconfl and returns the names of the
+// hosts which have a local configuration.
+func GetClusterConfigList() ([]string, error) {
+	return qconfList("-sconfl")
+}
+
+// GetClusterConfig calls qconf -sconf <name> and parses the output.
+// An empty name returns the global configuration.
+func GetClusterConfig(name string) (*ClusterConfig, error) {
+	if name == "" {
+		name = GlobalConfig
+	}
+	out, err := qconf("-sconf", name)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := ParseClusterConfig(string(out))
+	if err != nil {
+		return nil, err
+	}
+	cc.Name = name
+	return cc, nil
+}
+
+// GetEffectiveClusterConfig returns the global configuration merged
+// with the local configuration of the host. When the host has no local
+// configuration the global configuration is returned.
+func GetEffectiveClusterConfig(host string) (*ClusterConfig, error) {
+	global, err := GetClusterConfig(GlobalConfig)
+	if err != nil {
+		return nil, err
+	}
+	locals, err := GetClusterConfigList()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locals {
+		if l != host {
+			continue
+		}
+		local, err := GetClusterConfig(host)
+		if err != nil {
+			return nil, err
+		}
+		return MergeClusterConfig(*global, *local)
+	}
+	global.Name = host
+	return global, nil
+}
+
+// AddClusterConfig creates the local configuration of a host
+// (qconf -Aconf).
+func AddClusterConfig(cc ClusterConfig) error {
+	return qconfFromConfigFile("-Aconf", cc)
+}
+
+// ModifyClusterConfig replaces the global or a local configuration
+// (qconf -Mconf).
+func ModifyClusterConfig(cc ClusterConfig) error {
+	return qconfFromConfigFile("-Mconf", cc)
+}
+
+// DeleteClusterConfig removes the local configuration of the given
+// hosts (qconf -dconf).
+func DeleteClusterConfig(hosts ...string) error {
+	_, err := qconf("-dconf", strings.Join(hosts, ","))
+	return err
+}
+
+// qconfFromConfigFile executes qconf with a configuration file. qconf
+// takes the name of the configuration from the file name, hence the
+// file is written into a temporary directory.
+func qconfFromConfigFile(option string, cc ClusterConfig) error {
+	name := cc.Name
+	if name == "" {
+		name = GlobalConfig
+	}
+	dir, err := ioutil.TempDir("", "ugego")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(cc.String()), 0644); err != nil {
+		return err
+	}
+	_, err = qconf(option, file)
+	return err
+}
@@ -0,0 +1,141 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testGlobalConfig = `#global:
+execd_spool_dir              /opt/uge/default/spool
+mailer                       /bin/mail
+xterm                        /usr/bin/X11/xterm
+load_sensor                  none
+prolog                       none
+epilog                       root@/opt/epilog.sh
+shell_start_mode             unix_behavior
+login_shells                 sh,bash,ksh,csh,tcsh
+min_uid                      100
+load_report_time             00:00:40
+reschedule_unknown           00:10:00
+max_u_jobs                   100
+gid_range                    20000-20100
+qmaster_params               gdi_timeout=60 MONITOR_TIME=0:0:10
+execd_params                 ENABLE_BINDING=TRUE,KEEP_ACTIVE=ERROR
+reporting_params             accounting=true reporting=false \
+                             flush_time=00:00:15 joblog=false
+`
+
+var testLocalConfig = `#node1:
+mailer                       /usr/bin/mailx
+execd_params                 KEEP_ACTIVE=TRUE
+`
+
+func TestParseClusterConfig(t *testing.T) {
+	cc, err := ParseClusterConfig(testGlobalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Name != "global" {
+		t.Errorf("Name is not global, it is %s", cc.Name)
+	}
+	if cc.ExecdSpoolDir != "/opt/uge/default/spool" || cc.Epilog != "root@/opt/epilog.sh" {
+		t.Errorf("Wrong values: %v", cc)
+	}
+	if cc.LoadReportTime != 40*time.Second || cc.RescheduleUnknown != 10*time.Minute {
+		t.Errorf("Wrong times: %s %s", cc.LoadReportTime, cc.RescheduleUnknown)
+	}
+	if cc.MaxUJobs != 100 || cc.GidRange != "20000-20100" {
+		t.Errorf("Wrong values: %d %s", cc.MaxUJobs, cc.GidRange)
+	}
+	if cc.QmasterParams["gdi_timeout"] != "60" || cc.QmasterParams["MONITOR_TIME"] != "0:0:10" {
+		t.Errorf("Wrong qmaster_params: %v", cc.QmasterParams)
+	}
+	if cc.ReportingParams["flush_time"] != "00:00:15" || len(cc.ReportingParams) != 4 {
+		t.Errorf("Wrong reporting_params: %v", cc.ReportingParams)
+	}
+	if cc.Other["min_uid"] != "100" {
+		t.Errorf("Unknown attribute is not kept: %v", cc.Other)
+	}
+	again, err := ParseClusterConfig(cc.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != cc.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", cc, again)
+	}
+}
+
+func TestMergeClusterConfig(t *testing.T) {
+	global, err := ParseClusterConfig(testGlobalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := ParseClusterConfig(testLocalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := local.String(); strings.Count(s, "\n") != 2 {
+		t.Errorf("Local configuration must only contain 2 attributes:\n%s", s)
+	}
+	merged, err := MergeClusterConfig(*global, *local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Name != "node1" {
+		t.Errorf("Name is not node1, it is %s", merged.Name)
+	}
+	if merged.Mailer != "/usr/bin/mailx" || merged.MaxUJobs != 100 {
+		t.Errorf("Wrong merged values: %s %d", merged.Mailer, merged.MaxUJobs)
+	}
+	if len(merged.ExecdParams) != 1 || merged.ExecdParams["KEEP_ACTIVE"] != "TRUE" {
+		t.Errorf("Wrong merged execd_params: %v", merged.ExecdParams)
+	}
+}
+
+func TestClusterConfigSetAfterParse(t *testing.T) {
+	local, err := ParseClusterConfig(testLocalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local.Prolog = "root@/opt/prolog.sh"
+	local.MaxUJobs = 50
+	local.Other["min_gid"] = "100"
+	cc, err := ParseClusterConfig(local.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Prolog != "root@/opt/prolog.sh" || cc.MaxUJobs != 50 || cc.Other["min_gid"] != "100" {
+		t.Errorf("Changes after parsing were dropped:\n%s", local)
+	}
+	if cc.Mailer != "/usr/bin/mailx" || cc.ExecdSpoolDir != "" {
+		t.Errorf("Wrong attributes after round trip:\n%s", local)
+	}
+	global, err := ParseClusterConfig(testGlobalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := MergeClusterConfig(*global, *local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Prolog != "root@/opt/prolog.sh" || merged.MaxUJobs != 50 {
+		t.Errorf("Changes after parsing were not merged: %v", merged)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// acctquery selects accounting entries with a query expression (see
+// accounting.ParseQuery) and prints them as accounting lines, as jobs
+// like qacct -j, or as usage summary like qacct -o. Accounting lines are
+// printed as they are in the accounting file.
+//
+//	acctquery -f '/archive/accounting*' 'owner=alice and failed!=0 and l:h_vmem>4G'
+//	acctquery -by owner,project 'end>=2026-01-01'
+//	acctquery -j 'job=4711'
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/dgruber/ugego/pkg/accounting"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var summaryKeys = map[string]accounting.SummaryKey{
+	"owner":      accounting.SummaryOwner,
+	"group":      accounting.SummaryGroup,
+	"project":    accounting.SummaryProject,
+	"department": accounting.SummaryDepartment,
+	"queue":      accounting.SummaryQueue,
+	"host":       accounting.SummaryHost,
+	"pe":         accounting.SummaryPE,
+}
+
+func defaultAccountingFile() string {
+	cell := os.Getenv("SGE_CELL")
+	if cell == "" {
+		cell = "default"
+	}
+	return filepath.Join(os.Getenv("SGE_ROOT"), cell, "common", "accounting")
+}
+
+func main() {
+	files := flag.String("f", defaultAccountingFile(), "accounting file or glob of (compressed) accounting files")
+	jobs := flag.Bool("j", false, "print the selected jobs like qacct -j")
+	by := flag.String("by", "", "print a usage summary grouped by a comma separated list of owner, group, project, department, queue, host, and pe")
+	total := flag.Bool("total", false, "print the total usage of the selected entries")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [query]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*files, strings.Join(flag.Args(), " "), *jobs, *by, *total); err != nil {
+		log.Printf("Error during acctquery: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(files, query string, jobs bool, by string, total bool) error {
+	predicate, err := accounting.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	var keys []accounting.SummaryKey
+	if by != "" {
+		for _, name := range strings.Split(by, ",") {
+			k, exists := summaryKeys[strings.TrimSpace(name)]
+			if !exists {
+				return fmt.Errorf("unknown summary key %s", name)
+			}
+			keys = append(keys, k)
+		}
+	}
+	r, err := accounting.OpenGlob(files)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	summarizer := accounting.NewSummarizer(accounting.SummaryOptions{Keys: keys})
+	collector := accounting.NewJobCollector()
+	w := bufio.NewWriter(os.Stdout)
+	for r.Next() {
+		e := r.Entry()
+		if !predicate(e) {
+			continue
+		}
+		switch {
+		case by != "" || total:
+			summarizer.Add(e)
+		case jobs:
+			collector.Add(e)
+		default:
+			w.Write(r.Text())
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	switch {
+	case by != "" || total:
+		fmt.Print(summarizer.Summary())
+	case jobs:
+		for _, j := range collector.Jobs() {
+			fmt.Print(j)
+		}
+	}
+	return w.Flush()
+}
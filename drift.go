@@ -0,0 +1,269 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Types of changes of an ObjectChange.
+const (
+	ObjectAdded   = "added"
+	ObjectRemoved = "removed"
+	ObjectChanged = "changed"
+)
+
+// AttributeChange is an attribute of a configuration object which
+// differs between two snapshots. Old is empty for added, New is empty
+// for removed attributes.
+type AttributeChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// ObjectChange is a configuration object which was added, removed, or
+// changed between two snapshots.
+type ObjectChange struct {
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Change     string            `json:"change"`
+	Attributes []AttributeChange `json:"attributes,omitempty"`
+}
+
+// Drift is the list of changes between two snapshots sorted by kind
+// (in restore order) and name.
+type Drift []ObjectChange
+
+// listAttributes are the attributes whose values are lists (separated
+// by commas or white spaces) in which the order of the entries is not
+// significant. All other values (like prolog or starter_method command
+// lines) are compared verbatim.
+var listAttributes = map[string]bool{
+	"hostlist": true, "pe_list": true, "ckpt_list": true, "owner_list": true,
+	"user_lists": true, "xuser_lists": true, "projects": true, "xprojects": true,
+	"acl": true, "xacl": true, "entries": true, "login_shells": true,
+	"qmaster_params": true, "execd_params": true, "reporting_params": true,
+	"params": true, "subordinate_list": true, "variant_list": true,
+	"complex_values": true, "load_thresholds": true, "suspend_thresholds": true,
+	"report_variables": true, "usage_scaling": true, "user_list": true,
+	"xuser_list": true, "queue_list": true,
+}
+
+// DiffSnapshots compares two snapshots attribute by attribute. The
+// order of list entries and of host (or variant) specific overrides is
+// not significant, the order of resource quota rules is.
+func DiffSnapshots(old, new Snapshot) (Drift, error) {
+	var drift Drift
+	for _, k := range objectKinds {
+		names := make(map[string]bool)
+		for name := range old[k.name] {
+			names[name] = true
+		}
+		for name := range new[k.name] {
+			names[name] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			oldObject, inOld := old[k.name][name]
+			newObject, inNew := new[k.name][name]
+			switch {
+			case !inOld:
+				drift = append(drift, ObjectChange{Kind: k.name, Name: name, Change: ObjectAdded})
+			case !inNew:
+				drift = append(drift, ObjectChange{Kind: k.name, Name: name, Change: ObjectRemoved})
+			default:
+				changes, err := diffObject(k.name, oldObject, newObject)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %s", k.name, name, err)
+				}
+				if len(changes) > 0 {
+					drift = append(drift, ObjectChange{Kind: k.name, Name: name,
+						Change: ObjectChanged, Attributes: changes})
+				}
+			}
+		}
+	}
+	return drift, nil
+}
+
+// DiffLive compares a snapshot with the current configuration of the
+// cluster. Objects which only exist in the cluster are reported as added.
+func DiffLive(s Snapshot) (Drift, error) {
+	live, err := TakeSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return DiffSnapshots(s, live)
+}
+
+// diffObject compares the canonical attributes of two objects.
+func diffObject(kind, oldObject, newObject string) ([]AttributeChange, error) {
+	oldAttrs, err := canonicalAttributes(kind, oldObject)
+	if err != nil {
+		return nil, err
+	}
+	newAttrs, err := canonicalAttributes(kind, newObject)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for name := range oldAttrs {
+		names[name] = true
+	}
+	for name := range newAttrs {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	var changes []AttributeChange
+	for _, name := range sorted {
+		if oldAttrs[name] != newAttrs[name] {
+			changes = append(changes, AttributeChange{Name: name, Old: oldAttrs[name], New: newAttrs[name]})
+		}
+	}
+	return changes, nil
+}
+
+// canonicalAttributes converts an object in qconf file format into its
+// attributes with canonical values.
+func canonicalAttributes(kind, object string) (map[string]string, error) {
+	canonical := make(map[string]string)
+	switch kind {
+	case KindComplexes:
+		for _, line := range strings.Split(object, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			canonical[fields[0]] = strings.Join(fields[1:], " ")
+		}
+		return canonical, nil
+	case KindShareTree:
+		root, err := ParseShareTree(object)
+		if err != nil {
+			return nil, err
+		}
+		root.Walk(func(n *ShareTreeNode) {
+			canonical[n.Path()] = fmt.Sprintf("type=%d shares=%d", n.Type, n.Shares)
+		})
+		return canonical, nil
+	case KindRQS:
+		object = strings.TrimSpace(object)
+		object = strings.TrimSuffix(strings.TrimPrefix(object, "{"), "}")
+	}
+	attrs, err := parseAttributes(object)
+	if err != nil {
+		return nil, err
+	}
+	rule := 0
+	for _, a := range attrs {
+		name := a.name
+		if kind == KindRQS && name == "limit" {
+			// the order of the rules is significant
+			rule++
+			name = fmt.Sprintf("limit %d", rule)
+			canonical[name] = a.value
+			continue
+		}
+		canonical[name] = canonicalValue(kind, name, a.value)
+	}
+	return canonical, nil
+}
+
+// canonicalValue sorts the entries of list attributes and, for queues,
+// the host specific overrides of a value.
+func canonicalValue(kind, name, value string) string {
+	if kind != KindQueues {
+		return canonicalList(name, value)
+	}
+	def, overrides, err := splitHostOverrides(value)
+	if err != nil {
+		return value
+	}
+	parts := []string{canonicalList(name, def)}
+	var sorted []string
+	for _, o := range overrides {
+		sorted = append(sorted, "["+o.target+"="+canonicalList(name, o.value)+"]")
+	}
+	sort.Strings(sorted)
+	return strings.Join(append(parts, sorted...), ",")
+}
+
+// canonicalList returns the sorted comma separated entries of a list
+// attribute. Other values are returned unchanged.
+func canonicalList(name, value string) string {
+	if !listAttributes[name] {
+		return value
+	}
+	entries := splitList(value)
+	if len(entries) == 0 {
+		return "NONE"
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// String returns a text report of the drift.
+func (d Drift) String() string {
+	var buf bytes.Buffer
+	for _, c := range d {
+		switch c.Change {
+		case ObjectAdded:
+			fmt.Fprintf(&buf, "+ %s %s\n", c.Kind, c.Name)
+		case ObjectRemoved:
+			fmt.Fprintf(&buf, "- %s %s\n", c.Kind, c.Name)
+		default:
+			fmt.Fprintf(&buf, "~ %s %s\n", c.Kind, c.Name)
+			writeAttributeChanges(&buf, c.Attributes)
+		}
+	}
+	return buf.String()
+}
+
+// writeAttributeChanges writes one indented line per changed attribute.
+func writeAttributeChanges(buf *bytes.Buffer, changes []AttributeChange) {
+	for _, a := range changes {
+		switch {
+		case a.Old == "":
+			fmt.Fprintf(buf, "    + %s: %s\n", a.Name, a.New)
+		case a.New == "":
+			fmt.Fprintf(buf, "    - %s: %s\n", a.Name, a.Old)
+		default:
+			fmt.Fprintf(buf, "    ~ %s: %s -> %s\n", a.Name, a.Old, a.New)
+		}
+	}
+}
+
+// JSON returns the drift as indented JSON array.
+func (d Drift) JSON() ([]byte, error) {
+	if d == nil {
+		d = Drift{}
+	}
+	return json.MarshalIndent(d, "", "  ")
+}
@@ -0,0 +1,112 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	old := Snapshot{
+		KindQueues: {
+			"all.q":  "qname all.q\nhostlist @a @b\nslots 1,[node1=4],[@b=2]\npe_list make mpi\n",
+			"old.q":  "qname old.q\nhostlist NONE\n",
+			"same.q": "qname same.q\nuser_lists a,b\n",
+		},
+		KindRQS: {
+			"max": "{\n name max\n enabled TRUE\n limit users {*} to slots=10\n limit projects {*} to slots=20\n}\n",
+		},
+		KindShareTree: {
+			GlobalObject: "id=0\nname=Root\ntype=0\nshares=1\nchildnodes=1\nid=1\nname=P1\ntype=1\nshares=50\nchildnodes=NONE\n",
+		},
+	}
+	new := Snapshot{
+		KindQueues: {
+			"all.q":  "qname   all.q\nhostlist @b @a\nslots 1, [@b=2],[node1=8]\npe_list mpi make\nseq_no 1\n",
+			"new.q":  "qname new.q\nhostlist NONE\n",
+			"same.q": "qname same.q\nuser_lists b, a\n",
+		},
+		KindRQS: {
+			"max": "{\n name max\n enabled TRUE\n limit projects {*} to slots=20\n limit users {*} to slots=10\n}\n",
+		},
+		KindShareTree: {
+			GlobalObject: "id=0\nname=Root\ntype=0\nshares=1\nchildnodes=5\nid=5\nname=P1\ntype=1\nshares=50\nchildnodes=NONE\n",
+		},
+	}
+	drift, err := DiffSnapshots(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `~ queues all.q
+    + seq_no: 1
+    ~ slots: 1,[@b=2],[node1=4] -> 1,[@b=2],[node1=8]
++ queues new.q
+- queues old.q
+~ rqs max
+    ~ limit 1: users {*} to slots=10 -> projects {*} to slots=20
+    ~ limit 2: projects {*} to slots=20 -> users {*} to slots=10
+`
+	if got := drift.String(); got != expected {
+		t.Errorf("Wrong drift:\n%s\nexpected\n%s", got, expected)
+	}
+
+	js, err := drift.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Drift
+	if err := json.Unmarshal(js, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 4 || decoded[0].Change != ObjectChanged || decoded[1].Change != ObjectAdded ||
+		!strings.Contains(string(js), `"old": "1,[@b=2],[node1=4]"`) {
+		t.Errorf("Wrong JSON drift:\n%s", js)
+	}
+
+	none, err := DiffSnapshots(old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js, _ := none.JSON(); len(none) != 0 || string(js) != "[]" {
+		t.Errorf("Expected no drift but got %s", js)
+	}
+}
+
+func TestDiffSnapshotsCommandLines(t *testing.T) {
+	old := Snapshot{
+		KindConfig: {GlobalObject: "#global:\nprolog /bin/pro a,b\nlogin_shells sh,bash\n"},
+		KindQueues: {"all.q": "qname all.q\nstarter_method /bin/start -x b,a\nhostlist a b\n"},
+	}
+	new := Snapshot{
+		KindConfig: {GlobalObject: "#global:\nprolog /bin/pro b,a\nlogin_shells bash,sh\n"},
+		KindQueues: {"all.q": "qname all.q\nstarter_method /bin/start -x a,b\nhostlist b a\n"},
+	}
+	drift, err := DiffSnapshots(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 2 {
+		t.Fatalf("Expected changes of both objects but got %v", drift)
+	}
+	for _, c := range drift {
+		if len(c.Attributes) != 1 || (c.Attributes[0].Name != "prolog" && c.Attributes[0].Name != "starter_method") {
+			t.Errorf("Only the command lines must differ: %v", c.Attributes)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// LoadValue is a load value reported by an execution host. When the
+// value is numeric (like load_avg=0.5 or mem_free=1.2G) Numeric is set
+// and Value contains the number with memory units resolved into bytes.
+type LoadValue struct {
+	Raw     string
+	Value   float64
+	Numeric bool
+}
+
+// ExecHost is the configuration of a Univa Grid Engine execution host
+// as shown by qconf -se <host>.
+type ExecHost struct {
+	Name            string
+	LoadScaling     map[string]float64
+	ComplexValues   map[string]string
+	LoadValues      map[string]LoadValue
+	Processors      int
+	UserLists       []string
+	XUserLists      []string
+	Projects        []string
+	XProjects       []string
+	UsageScaling    map[string]float64
+	ReportVariables []string
+}
+
+// ParseExecHost parses an execution host in the format of qconf -se.
+// Attributes which are not part of the ExecHost struct are ignored.
+func ParseExecHost(eh string) (*ExecHost, error) {
+	attrs, err := parseAttributes(eh)
+	if err != nil {
+		return nil, err
+	}
+	var host ExecHost
+	for _, a := range attrs {
+		switch a.name {
+		case "hostname":
+			host.Name = a.value
+		case "load_scaling":
+			host.LoadScaling, err = parseScaling(a.value)
+		case "complex_values":
+			host.ComplexValues, err = parseKeyValues(a.value)
+		case "load_values":
+			host.LoadValues, err = parseLoadValues(a.value)
+		case "processors":
+			host.Processors, err = strconv.Atoi(a.value)
+		case "user_lists":
+			host.UserLists = splitList(a.value)
+		case "xuser_lists":
+			host.XUserLists = splitList(a.value)
+		case "projects":
+			host.Projects = splitList(a.value)
+		case "xprojects":
+			host.XProjects = splitList(a.value)
+		case "usage_scaling":
+			host.UsageScaling, err = parseScaling(a.value)
+		case "report_variables":
+			host.ReportVariables = splitList(a.value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error during %s parsing: %s", a.name, err)
+		}
+	}
+	if host.Name == "" {
+		return nil, errors.New("execution host has no hostname")
+	}
+	return &host, nil
+}
+
+// ComplexCapacity returns the numeric capacity configured for the
+// given consumable in complex_values of the host.
+func (eh ExecHost) ComplexCapacity(name string) (float64, error) {
+	value, exists := eh.ComplexValues[name]
+	if !exists {
+		return 0, fmt.Errorf("complex %s is not configured on host %s", name, eh.Name)
+	}
+	return ParseQuantity(value)
+}
+
+// parseLoadValues parses the load_values of an execution host.
+func parseLoadValues(value string) (map[string]LoadValue, error) {
+	kvs, err := parseKeyValues(value)
+	if err != nil {
+		return nil, err
+	}
+	lvs := make(map[string]LoadValue, len(kvs))
+	for name, raw := range kvs {
+		lv := LoadValue{Raw: raw}
+		if f, err := ParseQuantity(raw); err == nil {
+			lv.Value, lv.Numeric = f, true
+		}
+		lvs[name] = lv
+	}
+	return lvs, nil
+}
+
+// parseScaling parses scaling factors like "cpu=1.000000,mem=0.5".
+func parseScaling(value string) (map[string]float64, error) {
+	kvs, err := parseKeyValues(value)
+	if err != nil {
+		return nil, err
+	}
+	scaling := make(map[string]float64, len(kvs))
+	for name, raw := range kvs {
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, err
+		}
+		scaling[name] = f
+	}
+	return scaling, nil
+}
+
+// GetExecHostList calls qconf -sel and returns the names of all
+// execution hosts.
+func GetExecHostList() ([]string, error) {
+	return qconfList("-sel")
+}
+
+// GetExecHosts calls qconf -se <host> for each given host and parses
+// the output into ExecHost structs.
+func GetExecHosts(hosts ...string) ([]ExecHost, error) {
+	ehs := make([]ExecHost, 0, len(hosts))
+	for _, name := range hosts {
+		out, err := qconf("-se", name)
+		if err != nil {
+			return nil, err
+		}
+		eh, err := ParseExecHost(string(out))
+		if err != nil {
+			return nil, err
+		}
+		ehs = append(ehs, *eh)
+	}
+	return ehs, nil
+}
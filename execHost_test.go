@@ -0,0 +1,94 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"math"
+	"testing"
+)
+
+var testExecHost = `hostname              node1
+load_scaling          NONE
+complex_values        slots=16,h_vmem=64G,license=TRUE
+load_values           arch=lx-amd64,num_proc=16,mem_total=64314.000000M, \
+                      load_avg=0.010000,m_topology=SCCCCSCCCC
+processors            16
+user_lists            deadlineusers
+xuser_lists           NONE
+projects              NONE
+xprojects             NONE
+usage_scaling         cpu=1.000000,mem=0.500000,io=1.000000
+report_variables      NONE`
+
+func TestParseExecHost(t *testing.T) {
+	eh, err := ParseExecHost(testExecHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eh.Name != "node1" {
+		t.Errorf("Name is not node1, it is %s", eh.Name)
+	}
+	if eh.Processors != 16 {
+		t.Errorf("Processors is not 16, it is %d", eh.Processors)
+	}
+	if eh.ComplexValues["license"] != "TRUE" {
+		t.Errorf("Complex value license is not TRUE: %v", eh.ComplexValues)
+	}
+	if c, err := eh.ComplexCapacity("h_vmem"); err != nil || c != 64*1024*1024*1024 {
+		t.Errorf("Wrong h_vmem capacity %f (%v)", c, err)
+	}
+	if _, err := eh.ComplexCapacity("gpu"); err == nil {
+		t.Error("Expected error for not configured complex")
+	}
+	if lv := eh.LoadValues["mem_total"]; !lv.Numeric || lv.Value != 64314*1024*1024 {
+		t.Errorf("Wrong mem_total load value: %v", lv)
+	}
+	if lv := eh.LoadValues["load_avg"]; !lv.Numeric || lv.Value != 0.01 {
+		t.Errorf("Wrong load_avg load value: %v", lv)
+	}
+	if lv := eh.LoadValues["m_topology"]; lv.Numeric || lv.Raw != "SCCCCSCCCC" {
+		t.Errorf("Wrong m_topology load value: %v", lv)
+	}
+	if eh.UsageScaling["mem"] != 0.5 {
+		t.Errorf("Wrong mem usage scaling: %v", eh.UsageScaling)
+	}
+	if len(eh.UserLists) != 1 || eh.UserLists[0] != "deadlineusers" {
+		t.Errorf("Wrong user lists: %v", eh.UserLists)
+	}
+	if len(eh.XUserLists) != 0 || len(eh.ReportVariables) != 0 {
+		t.Errorf("NONE lists must be empty")
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := map[string]float64{
+		"1":        1,
+		"1.5k":     1500,
+		"2K":       2048,
+		"4G":       4 * 1024 * 1024 * 1024,
+		"1m":       1e6,
+		"INFINITY": math.Inf(1),
+	}
+	for value, expected := range tests {
+		if q, err := ParseQuantity(value); err != nil || q != expected {
+			t.Errorf("Expected %f for %s but got %f (%v)", expected, value, q, err)
+		}
+	}
+	if _, err := ParseQuantity("abc"); err == nil {
+		t.Error("Expected error for non numeric quantity")
+	}
+}
@@ -0,0 +1,289 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HostGroup is a Univa Grid Engine host group. The Hostlist can contain
+// host names as well as references to other host groups (starting with @).
+type HostGroup struct {
+	Name     string
+	Hostlist []string
+}
+
+// IsHostGroup returns true if the given name refers to a host group.
+func IsHostGroup(name string) bool {
+	return strings.HasPrefix(name, "@")
+}
+
+// ParseHostGroup parses a host group in the format of qconf -shgrp:
+// group_name @allhosts
+// hostlist   host1 host2 @subgroup
+func ParseHostGroup(hg string) (*HostGroup, error) {
+	attrs, err := parseAttributes(hg)
+	if err != nil {
+		return nil, err
+	}
+	var group HostGroup
+	for _, a := range attrs {
+		switch a.name {
+		case "group_name":
+			group.Name = a.value
+		case "hostlist":
+			group.Hostlist = splitList(a.value)
+		default:
+			return nil, fmt.Errorf("unknown host group attribute %s", a.name)
+		}
+	}
+	if group.Name == "" {
+		return nil, errors.New("host group has no group_name")
+	}
+	return &group, nil
+}
+
+// String returns the host group in the qconf file format.
+func (hg HostGroup) String() string {
+	hostlist := "NONE"
+	if len(hg.Hostlist) > 0 {
+		hostlist = strings.Join(hg.Hostlist, " ")
+	}
+	return fmt.Sprintf("group_name %s\nhostlist   %s\n", hg.Name, hostlist)
+}
+
+// GetHostGroupList calls qconf -shgrpl and returns the names of all
+// configured host groups.
+func GetHostGroupList() ([]string, error) {
+	return qconfList("-shgrpl")
+}
+
+// GetHostGroups calls qconf -shgrp <name> for each given host group
+// and parses the output into HostGroup structs.
+func GetHostGroups(names ...string) ([]HostGroup, error) {
+	groups := make([]HostGroup, 0, len(names))
+	for _, name := range names {
+		out, err := qconf("-shgrp", name)
+		if err != nil {
+			return nil, err
+		}
+		hg, err := ParseHostGroup(string(out))
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *hg)
+	}
+	return groups, nil
+}
+
+// GetResolvedHostGroup calls qconf -shgrp_resolved <name> and returns
+// all hosts of the host group including the hosts of nested groups.
+func GetResolvedHostGroup(name string) ([]string, error) {
+	out, err := qconf("-shgrp_resolved", name)
+	if err != nil {
+		return nil, err
+	}
+	return splitList(strings.TrimSpace(string(out))), nil
+}
+
+// ResolveHostGroups resolves the nested host group references of the
+// given host groups without contacting the cluster. The result maps
+// each host group name to its sorted list of hosts. An error is returned
+// when a referenced host group is not part of groups or when the
+// references form a cycle.
+func ResolveHostGroups(groups []HostGroup) (map[string][]string, error) {
+	byName := make(map[string]HostGroup, len(groups))
+	for _, hg := range groups {
+		byName[hg.Name] = hg
+	}
+	resolved := make(map[string][]string, len(groups))
+	for _, hg := range groups {
+		if _, err := resolveHostGroup(hg.Name, byName, resolved, nil); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// resolveHostGroup resolves a host group recursively. path contains the
+// host groups currently in resolution and is used for cycle detection.
+func resolveHostGroup(name string, byName map[string]HostGroup, resolved map[string][]string, path []string) ([]string, error) {
+	if hosts, exists := resolved[name]; exists {
+		return hosts, nil
+	}
+	for i, p := range path {
+		if p == name {
+			cycle := append(append([]string{}, path[i:]...), name)
+			return nil, fmt.Errorf("host group cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	hg, exists := byName[name]
+	if !exists {
+		if len(path) == 0 {
+			return nil, fmt.Errorf("host group %s does not exist", name)
+		}
+		return nil, fmt.Errorf("host group %s references unknown host group %s", path[len(path)-1], name)
+	}
+	path = append(path, name)
+	unique := make(map[string]bool)
+	for _, entry := range hg.Hostlist {
+		if !IsHostGroup(entry) {
+			unique[entry] = true
+			continue
+		}
+		hosts, err := resolveHostGroup(entry, byName, resolved, path)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hosts {
+			unique[h] = true
+		}
+	}
+	hosts := make([]string, 0, len(unique))
+	for h := range unique {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	resolved[name] = hosts
+	return hosts, nil
+}
+
+// HostGroupsOfHost returns the sorted names of all host groups the host
+// is member of, based on the output of ResolveHostGroups.
+func HostGroupsOfHost(host string, resolved map[string][]string) []string {
+	var groups []string
+	for name, hosts := range resolved {
+		for _, h := range hosts {
+			if h == host {
+				groups = append(groups, name)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// HostValue evaluates a queue configuration value with host or host
+// group specific overrides like "1,[@bigmem=4],[node1=8]" for the given
+// host. A host specific override takes precedence over a host group
+// override, which takes precedence over the default value. When the host
+// is in more than one of the overriding host groups the first one wins.
+// The resolved host groups are the output of ResolveHostGroups.
+func HostValue(value, host string, resolved map[string][]string) (string, error) {
+	def, overrides, err := splitHostOverrides(value)
+	if err != nil {
+		return "", err
+	}
+	groupValue, groupFound := "", false
+	for _, o := range overrides {
+		if !IsHostGroup(o.target) {
+			if o.target == host {
+				return o.value, nil
+			}
+			continue
+		}
+		if groupFound {
+			continue
+		}
+		for _, h := range resolved[o.target] {
+			if h == host {
+				groupValue, groupFound = o.value, true
+				break
+			}
+		}
+	}
+	if groupFound {
+		return groupValue, nil
+	}
+	return def, nil
+}
+
+// hostOverride is one [target=value] part of a queue configuration value.
+type hostOverride struct {
+	target string
+	value  string
+}
+
+// splitHostOverrides splits a queue configuration value into its default
+// value and the host / host group specific [target=value] overrides.
+func splitHostOverrides(value string) (string, []hostOverride, error) {
+	var def string
+	var overrides []hostOverride
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '[':
+			if depth == 0 {
+				if d := strings.Trim(value[start:i], " ,"); d != "" {
+					def = d
+				}
+				start = i + 1
+			}
+			depth++
+		case ']':
+			depth--
+			if depth < 0 {
+				return "", nil, fmt.Errorf("unbalanced ] in %s", value)
+			}
+			if depth == 0 {
+				o := value[start:i]
+				eq := strings.Index(o, "=")
+				if eq < 0 {
+					return "", nil, fmt.Errorf("override [%s] has no =", o)
+				}
+				overrides = append(overrides, hostOverride{
+					target: strings.TrimSpace(o[:eq]),
+					value:  strings.TrimSpace(o[eq+1:]),
+				})
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return "", nil, fmt.Errorf("unbalanced [ in %s", value)
+	}
+	if d := strings.Trim(value[start:], " ,"); d != "" {
+		def = d
+	}
+	return def, overrides, nil
+}
+
+// QueueInstanceHost returns the host name of a queue instance name
+// like all.q@host1 as it is reported by Qstatf.
+func QueueInstanceHost(queueInstance string) string {
+	if at := strings.Index(queueInstance, "@"); at >= 0 {
+		return queueInstance[at+1:]
+	}
+	return ""
+}
+
+// GroupQstatfByHostGroup aggregates the queue instances returned by
+// Qstatf by the host groups their host belongs to. Queue instances on
+// hosts which are not member of any host group are not returned.
+func GroupQstatfByHostGroup(queues []QstatQueue, resolved map[string][]string) map[string][]QstatQueue {
+	grouped := make(map[string][]QstatQueue)
+	for _, q := range queues {
+		for _, hg := range HostGroupsOfHost(QueueInstanceHost(q.Name), resolved) {
+			grouped[hg] = append(grouped[hg], q)
+		}
+	}
+	return grouped
+}
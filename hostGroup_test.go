@@ -0,0 +1,116 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHostGroup(t *testing.T) {
+	hg := `group_name @allhosts
+hostlist host1 host2 \
+         @bigmem`
+	g, err := ParseHostGroup(hg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "@allhosts" {
+		t.Errorf("Name is not @allhosts, it is %s", g.Name)
+	}
+	if len(g.Hostlist) != 3 {
+		t.Fatalf("Expected 3 hostlist entries but got %d", len(g.Hostlist))
+	}
+	if g.Hostlist[2] != "@bigmem" {
+		t.Errorf("Third entry needs to be @bigmem, but it is %s", g.Hostlist[2])
+	}
+	if g, err = ParseHostGroup("group_name @empty\nhostlist NONE"); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Hostlist) != 0 {
+		t.Errorf("Expected empty hostlist but got %v", g.Hostlist)
+	}
+}
+
+func TestResolveHostGroups(t *testing.T) {
+	groups := []HostGroup{
+		{Name: "@allhosts", Hostlist: []string{"host1", "@bigmem", "@gpu"}},
+		{Name: "@bigmem", Hostlist: []string{"host3", "host2"}},
+		{Name: "@gpu", Hostlist: []string{"host2", "@bigmem"}},
+	}
+	resolved, err := ResolveHostGroups(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := strings.Join(resolved["@allhosts"], ","); all != "host1,host2,host3" {
+		t.Errorf("Wrong resolved @allhosts: %s", all)
+	}
+	if gpu := strings.Join(resolved["@gpu"], ","); gpu != "host2,host3" {
+		t.Errorf("Wrong resolved @gpu: %s", gpu)
+	}
+
+	groups[1].Hostlist = append(groups[1].Hostlist, "@allhosts")
+	if _, err := ResolveHostGroups(groups); err == nil {
+		t.Error("Expected error for cyclic host groups")
+	}
+
+	if _, err := ResolveHostGroups([]HostGroup{{Name: "@a", Hostlist: []string{"@missing"}}}); err == nil {
+		t.Error("Expected error for unknown host group reference")
+	}
+}
+
+func TestHostValue(t *testing.T) {
+	resolved := map[string][]string{
+		"@bigmem": {"host2", "host3"},
+	}
+	value := "1,[@bigmem=4],[host3=8]"
+	tests := map[string]string{"host1": "1", "host2": "4", "host3": "8"}
+	for host, expected := range tests {
+		v, err := HostValue(value, host, resolved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Errorf("Expected %s for %s but got %s", expected, host, v)
+		}
+	}
+	v, err := HostValue("make,[@bigmem=make mpi]", "host2", resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "make mpi" {
+		t.Errorf("Expected \"make mpi\" but got %s", v)
+	}
+	if _, err := HostValue("1,[@bigmem=4", "host2", resolved); err == nil {
+		t.Error("Expected error for unbalanced brackets")
+	}
+}
+
+func TestGroupQstatfByHostGroup(t *testing.T) {
+	resolved := map[string][]string{
+		"@allhosts": {"host1", "host2"},
+		"@bigmem":   {"host2"},
+	}
+	queues := []QstatQueue{{Name: "all.q@host1"}, {Name: "all.q@host2"}, {Name: "all.q@host3"}}
+	grouped := GroupQstatfByHostGroup(queues, resolved)
+	if len(grouped["@allhosts"]) != 2 {
+		t.Errorf("Expected 2 queue instances in @allhosts but got %d", len(grouped["@allhosts"]))
+	}
+	if len(grouped["@bigmem"]) != 1 || grouped["@bigmem"][0].Name != "all.q@host2" {
+		t.Errorf("Wrong queue instances in @bigmem: %v", grouped["@bigmem"])
+	}
+}
@@ -0,0 +1,289 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is the severity of an integrity Finding.
+type Severity int
+
+const (
+	// SeverityWarning is a reference which is likely a mistake but is
+	// accepted by qconf, like a host which is no execution host
+	SeverityWarning Severity = iota
+	// SeverityError is a reference to an object which does not exist
+	// or an object which can't be parsed
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// MarshalText returns the name of the severity so that findings are
+// encoded with readable severities in JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding is a problem of a configuration object found by
+// CheckIntegrity. RefKind and Reference name the object which is
+// referenced but missing.
+type Finding struct {
+	Severity  Severity `json:"severity"`
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Attribute string   `json:"attribute,omitempty"`
+	RefKind   string   `json:"refKind,omitempty"`
+	Reference string   `json:"reference,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// String returns the finding in a single line.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s %s: %s", f.Severity, f.Kind, f.Name, f.Message)
+}
+
+// references maps the attributes of the kinds to the kind of the
+// objects which are referenced by the attribute values.
+var references = map[string]map[string]string{
+	KindQueues: {
+		"hostlist": KindHostGroups, "pe_list": KindPEs, "ckpt_list": KindCheckpoints,
+		"calendar": KindCalendars, "user_lists": KindUserLists, "xuser_lists": KindUserLists,
+		"projects": KindProjects, "xprojects": KindProjects, "subordinate_list": KindQueues,
+	},
+	KindHostGroups: {"hostlist": KindHostGroups},
+	KindExecHosts: {
+		"user_lists": KindUserLists, "xuser_lists": KindUserLists,
+		"projects": KindProjects, "xprojects": KindProjects,
+	},
+	KindConfig: {
+		"user_lists": KindUserLists, "xuser_lists": KindUserLists,
+		"projects": KindProjects, "xprojects": KindProjects,
+	},
+	KindPEs:      {"user_lists": KindUserLists, "xuser_lists": KindUserLists},
+	KindProjects: {"acl": KindUserLists, "xacl": KindUserLists},
+	KindUsers:    {"default_project": KindProjects},
+}
+
+// integrityChecker collects the findings of a snapshot.
+type integrityChecker struct {
+	s        Snapshot
+	findings []Finding
+}
+
+// CheckIntegrity checks that all objects which are referenced by the
+// objects of the snapshot exist in the snapshot: host groups, parallel
+// environments, checkpointing environments, calendars, user lists, and
+// projects of queues; user lists and host groups of resource quota sets;
+// and projects and departments of the share tree. References into kinds
+// which are not part of the snapshot are not checked. The findings are
+// ordered by kind (in restore order) and object name.
+func CheckIntegrity(s Snapshot) []Finding {
+	c := integrityChecker{s: s}
+	for _, k := range objectKinds {
+		for _, name := range s.Names(k.name) {
+			object := s[k.name][name]
+			switch k.name {
+			case KindRQS:
+				c.checkRQS(name, object)
+			case KindShareTree:
+				c.checkShareTree(name, object)
+			case KindComplexes, KindScheduler:
+			default:
+				c.checkAttributes(k.name, name, object)
+			}
+		}
+	}
+	return c.findings
+}
+
+func (c *integrityChecker) add(severity Severity, kind, name, attr, refKind, ref, message string) {
+	c.findings = append(c.findings, Finding{Severity: severity, Kind: kind, Name: name,
+		Attribute: attr, RefKind: refKind, Reference: ref, Message: message})
+}
+
+// exists returns true if the object exists or if the kind is not part
+// of the snapshot.
+func (c *integrityChecker) exists(kind, name string) bool {
+	objects, captured := c.s[kind]
+	if !captured {
+		return true
+	}
+	_, exists := objects[name]
+	return exists
+}
+
+// reference adds an error when the referenced object does not exist.
+func (c *integrityChecker) reference(kind, name, attr, refKind, ref string) {
+	if ref == "" || strings.EqualFold(ref, "NONE") || c.exists(refKind, ref) {
+		return
+	}
+	c.add(SeverityError, kind, name, attr, refKind, ref,
+		fmt.Sprintf("%s references unknown %s %s", attr, refKind, ref))
+}
+
+// host checks a host or host group reference. Hosts which are no
+// execution hosts are reported as warning.
+func (c *integrityChecker) host(kind, name, attr, host string) {
+	switch {
+	case IsHostGroup(host):
+		c.reference(kind, name, attr, KindHostGroups, host)
+	case host != "" && !strings.EqualFold(host, "NONE") && !c.exists(KindExecHosts, host):
+		c.add(SeverityWarning, kind, name, attr, KindExecHosts, host,
+			fmt.Sprintf("%s references host %s which is no execution host", attr, host))
+	}
+}
+
+func (c *integrityChecker) checkAttributes(kind, name, object string) {
+	attrs, err := parseAttributes(object)
+	if err != nil {
+		c.add(SeverityError, kind, name, "", "", "", fmt.Sprintf("can't be parsed: %s", err))
+		return
+	}
+	for _, a := range attrs {
+		refKind, exists := references[kind][a.name]
+		if !exists {
+			continue
+		}
+		def, overrides, err := splitHostOverrides(a.value)
+		if err != nil {
+			c.add(SeverityError, kind, name, a.name, "", "", err.Error())
+			continue
+		}
+		values := []string{def}
+		for _, o := range overrides {
+			c.host(kind, name, a.name, o.target)
+			values = append(values, o.value)
+		}
+		for _, value := range values {
+			for _, ref := range referencedNames(a.name, value) {
+				if a.name == "hostlist" {
+					c.host(kind, name, a.name, ref)
+				} else {
+					c.reference(kind, name, a.name, refKind, ref)
+				}
+			}
+		}
+	}
+}
+
+// referencedNames returns the object names of an attribute value. The
+// subordinate_list contains queue=threshold entries or, for slot wise
+// subordination, slots=n(queue:seq_no:action, ...).
+func referencedNames(attr, value string) []string {
+	if attr != "subordinate_list" {
+		return splitList(value)
+	}
+	if strings.HasPrefix(strings.TrimSpace(value), "slots=") {
+		open, end := strings.Index(value, "("), strings.LastIndex(value, ")")
+		if open < 0 || end < open {
+			return nil
+		}
+		var names []string
+		for _, e := range strings.Split(value[open+1:end], ",") {
+			names = append(names, strings.TrimSpace(strings.SplitN(e, ":", 2)[0]))
+		}
+		return names
+	}
+	var names []string
+	for _, e := range splitList(value) {
+		names = append(names, strings.SplitN(e, "=", 2)[0])
+	}
+	return names
+}
+
+// checkRQS checks the user lists (@name in users), host groups (@name
+// in hosts), projects, parallel environments, and queues of the rules.
+// Negated entries are checked as well, wildcard patterns are skipped.
+func (c *integrityChecker) checkRQS(name, object string) {
+	sets, err := ParseResourceQuotaSets(object)
+	if err != nil {
+		c.add(SeverityError, KindRQS, name, "", "", "", fmt.Sprintf("can't be parsed: %s", err))
+		return
+	}
+	for _, set := range sets {
+		for i, rule := range set.Rules {
+			attr := "limit " + set.RuleID(i)
+			check := func(f QuotaFilter, refKind string, groupsOnly bool) {
+				for _, e := range f.Entries {
+					e = strings.TrimPrefix(e, "!")
+					if strings.ContainsAny(e, "*?[") {
+						continue
+					}
+					if groupsOnly {
+						if !strings.HasPrefix(e, "@") {
+							continue
+						}
+						if refKind == KindUserLists {
+							e = e[1:]
+						}
+					}
+					c.reference(KindRQS, name, attr, refKind, e)
+				}
+			}
+			check(rule.Users, KindUserLists, true)
+			check(rule.Hosts, KindHostGroups, true)
+			check(rule.Projects, KindProjects, false)
+			check(rule.PEs, KindPEs, false)
+			check(rule.Queues, KindQueues, false)
+		}
+	}
+}
+
+// checkShareTree checks that project nodes are projects and that inner
+// user nodes (below the root) are departments, i.e. user lists of type
+// DEPT.
+func (c *integrityChecker) checkShareTree(name, object string) {
+	root, err := ParseShareTree(object)
+	if err != nil {
+		c.add(SeverityError, KindShareTree, name, "", "", "", fmt.Sprintf("can't be parsed: %s", err))
+		return
+	}
+	root.Walk(func(n *ShareTreeNode) {
+		switch {
+		case n.Type == ShareTreeProjectNode:
+			c.reference(KindShareTree, name, n.Path(), KindProjects, n.Name)
+		case n != root && len(n.Children) > 0:
+			if !c.exists(KindUserLists, n.Name) {
+				c.add(SeverityError, KindShareTree, name, n.Path(), KindUserLists, n.Name,
+					fmt.Sprintf("department %s has no user list", n.Name))
+				return
+			}
+			object, captured := c.s[KindUserLists][n.Name]
+			if !captured {
+				// the user lists are not part of the snapshot
+				return
+			}
+			attrs, err := parseAttributes(object)
+			if err == nil && !isDepartment(UserList{Type: attributeMap(attrs)["type"]}) {
+				c.add(SeverityError, KindShareTree, name, n.Path(), KindUserLists, n.Name,
+					fmt.Sprintf("user list %s is not of type DEPT", n.Name))
+			}
+		}
+	})
+}
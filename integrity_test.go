@@ -0,0 +1,97 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	s := Snapshot{
+		KindUserLists: {
+			"staff":   "name staff\ntype ACL\nfshare 0\noticket 0\nentries alice\n",
+			"physics": "name physics\ntype DEPT\nfshare 0\noticket 0\nentries bob\n",
+		},
+		KindProjects:    {"p1": "name p1\noticket 0\nfshare 0\nacl staff\nxacl missing_acl\n"},
+		KindCalendars:   {},
+		KindCheckpoints: {},
+		KindPEs:         {"mpi": "pe_name mpi\nslots 8\nuser_lists NONE\nxuser_lists NONE\n"},
+		KindExecHosts:   {"node1": "hostname node1\n"},
+		KindHostGroups:  {"@all": "group_name @all\nhostlist node1 @missing\n"},
+		KindQueues: {
+			"all.q": "qname all.q\nhostlist @all node2\npe_list mpi,[@gpu=mpi smp]\n" +
+				"ckpt_list NONE\ncalendar night\nuser_lists staff\nsubordinate_list slots=4(low.q:1:sr)\n",
+		},
+		KindRQS: {
+			"max": "{\n name max\n enabled TRUE\n limit users {@staff,!@nobody} projects p2 hosts @all to slots=10\n limit users * to slots=20\n}\n",
+		},
+		KindShareTree: {
+			GlobalObject: "id=0\nname=Root\ntype=0\nshares=1\nchildnodes=1,2,3\n" +
+				"id=1\nname=physics\ntype=0\nshares=10\nchildnodes=4\n" +
+				"id=2\nname=staff\ntype=0\nshares=10\nchildnodes=5\n" +
+				"id=3\nname=p3\ntype=1\nshares=10\nchildnodes=NONE\n" +
+				"id=4\nname=bob\ntype=0\nshares=1\nchildnodes=NONE\n" +
+				"id=5\nname=alice\ntype=0\nshares=1\nchildnodes=NONE\n",
+		},
+	}
+	findings := CheckIntegrity(s)
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	expected := []string{
+		"error: projects p1: xacl references unknown userlists missing_acl",
+		"error: hostgroups @all: hostlist references unknown hostgroups @missing",
+		"warning: queues all.q: hostlist references host node2 which is no execution host",
+		"error: queues all.q: pe_list references unknown hostgroups @gpu",
+		"error: queues all.q: pe_list references unknown pes smp",
+		"error: queues all.q: calendar references unknown calendars night",
+		"error: queues all.q: subordinate_list references unknown queues low.q",
+		"error: rqs max: limit max/1 references unknown userlists nobody",
+		"error: rqs max: limit max/1 references unknown projects p2",
+		"error: sharetree global: user list staff is not of type DEPT",
+		"error: sharetree global: /p3 references unknown projects p3",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Wrong findings:\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	js, err := json.Marshal(findings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(js), `"severity":"error"`) || !strings.Contains(string(js), `"reference":"missing_acl"`) {
+		t.Errorf("Wrong JSON finding: %s", js)
+	}
+}
+
+func TestCheckIntegrityWithoutUserLists(t *testing.T) {
+	s := Snapshot{
+		KindProjects: {"p1": "name p1\noticket 0\nfshare 0\nacl NONE\nxacl NONE\n"},
+		KindShareTree: {
+			GlobalObject: "id=0\nname=Root\ntype=0\nshares=1\nchildnodes=1,2\n" +
+				"id=1\nname=physics\ntype=0\nshares=10\nchildnodes=3\n" +
+				"id=2\nname=p1\ntype=1\nshares=10\nchildnodes=NONE\n" +
+				"id=3\nname=bob\ntype=0\nshares=1\nchildnodes=NONE\n",
+		},
+	}
+	if findings := CheckIntegrity(s); len(findings) != 0 {
+		t.Errorf("Expected no findings without user lists but got %v", findings)
+	}
+}
@@ -0,0 +1,333 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JobClassAccess is the access specifier of a job class attribute which
+// determines if a job submitted into the job class may override it.
+type JobClassAccess int
+
+const (
+	// JobClassReadOnly ({-}) means the attribute can't be changed
+	JobClassReadOnly JobClassAccess = iota
+	// JobClassModifiable ({~}) means the value can be changed but the
+	// attribute can't be added or removed
+	JobClassModifiable
+	// JobClassFull ({+}) means the attribute can be changed, added, and
+	// removed
+	JobClassFull
+)
+
+// String returns the access specifier like {+}.
+func (a JobClassAccess) String() string {
+	switch a {
+	case JobClassModifiable:
+		return "{~}"
+	case JobClassFull:
+		return "{+}"
+	}
+	return "{-}"
+}
+
+// Unspecified is the value of a job class attribute which is not set.
+const Unspecified = "UNSPECIFIED"
+
+// JobClassElement is an element of a list attribute of a job class like
+// h_vmem=4G of l_hard, with its own access specifier.
+type JobClassElement struct {
+	Access JobClassAccess
+	Name   string
+	Value  string
+}
+
+// JobClassValue is the value of a job class attribute for one variant.
+// For list attributes (like l_hard or v) Elements contains the list
+// entries, elements without an own access specifier inherit the access
+// of the attribute.
+type JobClassValue struct {
+	Access   JobClassAccess
+	Value    string
+	Elements []JobClassElement
+}
+
+// IsSet returns false if the attribute is UNSPECIFIED.
+func (v JobClassValue) IsSet() bool {
+	return v.Value != "" && v.Value != Unspecified
+}
+
+// element returns the list element with the given name.
+func (v JobClassValue) element(name string) (JobClassElement, bool) {
+	for _, e := range v.Elements {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return JobClassElement{}, false
+}
+
+// JobClass is a Univa Grid Engine job class as shown by qconf -sjc.
+// Attributes contains the raw values of the job template attributes
+// (like l_hard or N) including access specifiers and variant overrides
+// ([variant=value]).
+type JobClass struct {
+	Name        string
+	VariantList []string
+	Owner       string
+	UserLists   []string
+	XUserLists  []string
+	Attributes  map[string]string
+	// order keeps the attribute order of qconf for writing
+	order []string
+}
+
+// ParseJobClass parses a job class in the format of qconf -sjc.
+func ParseJobClass(jc string) (*JobClass, error) {
+	attrs, err := parseAttributes(jc)
+	if err != nil {
+		return nil, err
+	}
+	c := JobClass{Attributes: make(map[string]string)}
+	for _, a := range attrs {
+		switch a.name {
+		case "jcname":
+			c.Name = a.value
+		case "variant_list":
+			c.VariantList = splitList(a.value)
+		case "owner":
+			if a.value != "NONE" {
+				c.Owner = a.value
+			}
+		case "user_lists":
+			c.UserLists = splitList(a.value)
+		case "xuser_lists":
+			c.XUserLists = splitList(a.value)
+		default:
+			if _, _, err := splitHostOverrides(a.value); err != nil {
+				return nil, fmt.Errorf("error during %s parsing: %s", a.name, err)
+			}
+			c.Attributes[a.name] = a.value
+			c.order = append(c.order, a.name)
+		}
+	}
+	if c.Name == "" {
+		return nil, errors.New("job class has no jcname")
+	}
+	return &c, nil
+}
+
+// String returns the job class in the qconf file format.
+func (c JobClass) String() string {
+	owner := c.Owner
+	if owner == "" {
+		owner = "NONE"
+	}
+	attrs := []attribute{
+		{"jcname", c.Name},
+		{"variant_list", joinList(c.VariantList)},
+		{"owner", owner},
+		{"user_lists", joinList(c.UserLists)},
+		{"xuser_lists", joinList(c.XUserLists)},
+	}
+	written := make(map[string]bool, len(c.Attributes))
+	for _, name := range c.order {
+		if value, exists := c.Attributes[name]; exists && !written[name] {
+			attrs = append(attrs, attribute{name, value})
+			written[name] = true
+		}
+	}
+	var rest []string
+	for name := range c.Attributes {
+		if !written[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		attrs = append(attrs, attribute{name, c.Attributes[name]})
+	}
+	return formatAttributes(attrs)
+}
+
+// Attribute returns the value of the job template attribute for the
+// given variant. An empty variant or a variant without override returns
+// the default value.
+func (c JobClass) Attribute(name, variant string) (JobClassValue, error) {
+	raw, exists := c.Attributes[name]
+	if !exists {
+		return JobClassValue{Access: JobClassReadOnly, Value: Unspecified}, nil
+	}
+	def, overrides, err := splitHostOverrides(raw)
+	if err != nil {
+		return JobClassValue{}, err
+	}
+	value := def
+	for _, o := range overrides {
+		if o.target == variant {
+			value = o.value
+		}
+	}
+	return ParseJobClassValue(value), nil
+}
+
+// ParseJobClassValue parses an attribute value of a job class like
+// {+}UNSPECIFIED or {~}h_vmem=4G,{-}arch=lx-amd64. A value without
+// access specifier is read-only.
+func ParseJobClassValue(value string) JobClassValue {
+	v := JobClassValue{}
+	v.Access, v.Value = splitAccess(value)
+	if !v.IsSet() || !strings.Contains(v.Value, "=") {
+		return v
+	}
+	for _, e := range strings.Split(v.Value, ",") {
+		access, rest := splitAccess(e)
+		if rest == e {
+			access = v.Access
+		}
+		el := JobClassElement{Access: access, Name: rest}
+		if eq := strings.Index(rest, "="); eq >= 0 {
+			el.Name, el.Value = rest[:eq], rest[eq+1:]
+		}
+		v.Elements = append(v.Elements, el)
+	}
+	return v
+}
+
+// splitAccess removes a leading access specifier from the value.
+func splitAccess(value string) (JobClassAccess, string) {
+	switch {
+	case strings.HasPrefix(value, "{+}"):
+		return JobClassFull, value[3:]
+	case strings.HasPrefix(value, "{~}"):
+		return JobClassModifiable, value[3:]
+	case strings.HasPrefix(value, "{-}"):
+		return JobClassReadOnly, value[3:]
+	}
+	return JobClassReadOnly, value
+}
+
+// JobClassViolation describes a part of a submit request which is not
+// allowed to be overridden by the job class.
+type JobClassViolation struct {
+	Attribute string
+	Element   string
+	Reason    string
+}
+
+// String returns a human readable description of the violation.
+func (v JobClassViolation) String() string {
+	return fmt.Sprintf("%s %s: %s", v.Attribute, v.Element, v.Reason)
+}
+
+// SubmitRequests are the resource requests, environment variables, and
+// the job class of a submit request. It has the fields of the
+// accounting package's SubmitRequests, hence a parsed submit command
+// can be converted:
+//
+//	r := ugego.SubmitRequests(accounting.ParseSubmitCommand(cmd))
+type SubmitRequests struct {
+	Complexes   map[string]string
+	Environment map[string]string
+	JobClass    string
+}
+
+// CheckSubmitRequests checks if the resource requests (-l, mapped to
+// l_hard) and environment variables (-v) of a submit request are
+// allowed by the access specifiers of the job class. The variant is
+// taken from the requested job class (like -jc class.variant).
+func (c JobClass) CheckSubmitRequests(r SubmitRequests) ([]JobClassViolation, error) {
+	variant := ""
+	if dot := strings.Index(r.JobClass, "."); dot >= 0 {
+		variant = r.JobClass[dot+1:]
+	}
+	var violations []JobClassViolation
+	for _, check := range []struct {
+		attribute string
+		requested map[string]string
+	}{{"l_hard", r.Complexes}, {"v", r.Environment}} {
+		value, err := c.Attribute(check.attribute, variant)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(check.requested))
+		for name := range check.requested {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			el, exists := value.element(name)
+			switch {
+			case !exists && value.Access != JobClassFull:
+				violations = append(violations, JobClassViolation{check.attribute, name,
+					fmt.Sprintf("adding is not allowed (%s)", value.Access)})
+			case exists && el.Value != check.requested[name] && el.Access == JobClassReadOnly:
+				violations = append(violations, JobClassViolation{check.attribute, name,
+					fmt.Sprintf("changing %s to %s is not allowed (%s)", el.Value, check.requested[name], el.Access)})
+			}
+		}
+	}
+	return violations, nil
+}
+
+// GetJobClassList calls qconf -sjcl and returns the names of all job
+// classes.
+func GetJobClassList() ([]string, error) {
+	return qconfList("-sjcl")
+}
+
+// GetJobClasses calls qconf -sjc <name> for each given job class and
+// parses the output into JobClass structs.
+func GetJobClasses(names ...string) ([]JobClass, error) {
+	jcs := make([]JobClass, 0, len(names))
+	for _, name := range names {
+		out, err := qconf("-sjc", name)
+		if err != nil {
+			return nil, err
+		}
+		jc, err := ParseJobClass(string(out))
+		if err != nil {
+			return nil, err
+		}
+		jcs = append(jcs, *jc)
+	}
+	return jcs, nil
+}
+
+// AddJobClass creates the job class in the cluster (qconf -Ajc).
+func AddJobClass(c JobClass) error {
+	return qconfFromFile("-Ajc", c.String())
+}
+
+// ModifyJobClass replaces an existing job class (qconf -Mjc).
+func ModifyJobClass(c JobClass) error {
+	return qconfFromFile("-Mjc", c.String())
+}
+
+// DeleteJobClasses removes the given job classes (qconf -djc).
+func DeleteJobClasses(names ...string) error {
+	for _, name := range names {
+		if _, err := qconf("-djc", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
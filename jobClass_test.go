@@ -0,0 +1,98 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"github.com/dgruber/ugego/pkg/accounting"
+	"testing"
+)
+
+var testJobClass = `jcname          sleeper
+variant_list    default,long
+owner           NONE
+user_lists      NONE
+xuser_lists     NONE
+A               {+}UNSPECIFIED
+CMDNAME         /bin/sleep
+l_hard          {~}h_rt=1:00:00,{-}arch=lx-amd64,[long={~}h_rt=24:00:00,{-}arch=lx-amd64]
+N               {~}Sleeper
+v               {+}UNSPECIFIED`
+
+func TestParseJobClass(t *testing.T) {
+	jc, err := ParseJobClass(testJobClass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jc.Name != "sleeper" || len(jc.VariantList) != 2 {
+		t.Errorf("Wrong job class: %v", jc)
+	}
+	v, err := jc.Attribute("l_hard", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Access != JobClassModifiable || len(v.Elements) != 2 {
+		t.Fatalf("Wrong l_hard: %v", v)
+	}
+	if v.Elements[0].Name != "h_rt" || v.Elements[0].Access != JobClassModifiable || v.Elements[0].Value != "1:00:00" {
+		t.Errorf("Wrong h_rt element: %v", v.Elements[0])
+	}
+	if v.Elements[1].Access != JobClassReadOnly {
+		t.Errorf("arch must be read-only: %v", v.Elements[1])
+	}
+	if v, _ = jc.Attribute("l_hard", "long"); v.Elements[0].Value != "24:00:00" {
+		t.Errorf("Wrong h_rt for variant long: %v", v.Elements[0])
+	}
+	if v, _ = jc.Attribute("CMDNAME", ""); v.Access != JobClassReadOnly || v.Value != "/bin/sleep" {
+		t.Errorf("Wrong CMDNAME: %v", v)
+	}
+	if v, _ = jc.Attribute("A", ""); v.IsSet() || v.Access != JobClassFull {
+		t.Errorf("Wrong A: %v", v)
+	}
+	again, err := ParseJobClass(jc.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != jc.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", jc, again)
+	}
+}
+
+func TestCheckSubmitRequests(t *testing.T) {
+	jc, err := ParseJobClass(testJobClass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := SubmitRequests(accounting.ParseSubmitCommand("qsub -jc sleeper -l h_rt=2:00:00 -v FOO=bar /bin/sleep"))
+	violations, err := jc.CheckSubmitRequests(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("Expected no violations but got %v", violations)
+	}
+	r = SubmitRequests(accounting.ParseSubmitCommand("qsub -jc sleeper.long -l arch=lx-x86,h_vmem=4G /bin/sleep"))
+	violations, err = jc.CheckSubmitRequests(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations but got %v", violations)
+	}
+	if violations[0].Element != "arch" || violations[1].Element != "h_vmem" {
+		t.Errorf("Wrong violations: %v", violations)
+	}
+}
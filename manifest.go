@@ -0,0 +1,414 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dgruber/ugego/Godeps/_workspace/src/gopkg.in/yaml.v2"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Manifest describes the desired access lists, departments, projects,
+// queues, and parallel environments of a cluster. It is written in YAML
+//
+//	acls:
+//	  - name: staff
+//	    entries: [alice, "%wheel"]
+//	departments:
+//	  - name: physics
+//	    fshare: 100
+//	    entries: [bob]
+//	projects:
+//	  - name: p1
+//	    acl: [staff]
+//	queues:
+//	  all.q: {slots: 4, pe_list: mpi}
+//	pes:
+//	  mpi: {slots: 64, allocation_rule: $fill_up}
+//	prune: true
+//
+// or in JSON:
+//
+//	{
+//	  "acls":        [{"name": "staff", "entries": ["alice", "%wheel"]}],
+//	  "departments": [{"name": "physics", "fshare": 100, "entries": ["bob"]}],
+//	  "projects":    [{"name": "p1", "acl": ["staff"]}],
+//	  "queues":      {"all.q": {"slots": "4", "pe_list": "mpi"}},
+//	  "pes":         {"mpi": {"slots": "64", "allocation_rule": "$fill_up"}},
+//	  "prune":       true
+//	}
+//
+// User lists and projects are specified completely. Queues and parallel
+// environments only contain the attributes which should be set, all
+// others keep their current value (or the default of a new object).
+// With Prune set the objects of the specified sections which are not in
+// the manifest are deleted, except the built-in user lists arusers,
+// deadlineusers, and defaultdepartment. Sections which are missing are
+// not managed.
+type Manifest struct {
+	ACLs        []UserList                   `json:"acls" yaml:"acls"`
+	Departments []UserList                   `json:"departments" yaml:"departments"`
+	Projects    []Project                    `json:"projects" yaml:"projects"`
+	Queues      map[string]map[string]string `json:"queues" yaml:"queues"`
+	PEs         map[string]map[string]string `json:"pes" yaml:"pes"`
+	Prune       bool                         `json:"prune" yaml:"prune"`
+}
+
+// Operations of a PlanStep.
+const (
+	PlanAdd    = "add"
+	PlanModify = "modify"
+	PlanDelete = "delete"
+)
+
+// PlanStep is a qconf operation which brings an object into the state
+// of the manifest. Object is the desired object in qconf file format,
+// Previous the current one which is used to roll back the step.
+type PlanStep struct {
+	Kind     string
+	Name     string
+	Op       string
+	Object   string
+	Previous string
+	Changes  []AttributeChange
+}
+
+// Plan contains the steps in the order in which they are applied.
+// Objects are added and modified before the objects which reference
+// them (user lists, projects, parallel environments, queues) and deleted
+// in reverse order.
+type Plan []PlanStep
+
+// defaultPE contains the attributes of a new parallel environment
+// which are not set in the manifest.
+var defaultPE = []attribute{
+	{"pe_name", ""},
+	{"slots", "0"},
+	{"user_lists", "NONE"},
+	{"xuser_lists", "NONE"},
+	{"start_proc_args", "NONE"},
+	{"stop_proc_args", "NONE"},
+	{"allocation_rule", "$pe_slots"},
+	{"control_slaves", "FALSE"},
+	{"job_is_first_task", "TRUE"},
+	{"urgency_slots", "min"},
+	{"accounting_summary", "FALSE"},
+}
+
+// ParseManifest parses a manifest in YAML or JSON format. Manifests
+// which start with { are JSON.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if err := decoder.Decode(&m); err != nil {
+			return nil, fmt.Errorf("error during manifest parsing: %s", err)
+		}
+	} else if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error during manifest parsing: %s", err)
+	}
+	for i := range m.ACLs {
+		if m.ACLs[i].Type == "" {
+			m.ACLs[i].Type = "ACL"
+		}
+	}
+	for i := range m.Departments {
+		if m.Departments[i].Type == "" {
+			m.Departments[i].Type = "DEPT"
+		}
+	}
+	return &m, nil
+}
+
+// LoadManifest reads and parses a manifest file.
+func LoadManifest(file string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// desiredObjects returns the objects of the manifest in qconf file
+// format. Queues and parallel environments are merged into the live
+// object or, for new objects, into the queue template respectively the
+// default parallel environment.
+func (m Manifest) desiredObjects(kind string, live map[string]string) (map[string]string, error) {
+	desired := make(map[string]string)
+	switch kind {
+	case KindUserLists:
+		for _, ul := range append(append([]UserList{}, m.ACLs...), m.Departments...) {
+			if _, exists := desired[ul.Name]; exists {
+				return nil, fmt.Errorf("user list %s is defined twice", ul.Name)
+			}
+			desired[ul.Name] = ul.String()
+		}
+	case KindProjects:
+		for _, p := range m.Projects {
+			if _, exists := desired[p.Name]; exists {
+				return nil, fmt.Errorf("project %s is defined twice", p.Name)
+			}
+			desired[p.Name] = p.String()
+		}
+	case KindQueues, KindPEs:
+		objects, nameAttr, template := m.Queues, "qname", ""
+		if kind == KindPEs {
+			objects, nameAttr, template = m.PEs, "pe_name", formatAttributes(defaultPE)
+		}
+		for name, set := range objects {
+			base, exists := live[name]
+			if !exists {
+				if kind == KindQueues && template == "" {
+					out, err := qconf("-sq")
+					if err != nil {
+						return nil, err
+					}
+					template = string(out)
+				}
+				base = template
+			}
+			object, err := mergeAttributes(base, nameAttr, name, set)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s", kind, name, err)
+			}
+			desired[name] = object
+		}
+	}
+	return desired, nil
+}
+
+// builtinObjects are the objects which Grid Engine creates itself and
+// which are required by qmaster (like the department of users which
+// are in no other department). They are never pruned.
+var builtinObjects = map[string]map[string]bool{
+	KindUserLists: {"arusers": true, "deadlineusers": true, "defaultdepartment": true},
+}
+
+// managed returns true if the manifest contains a section for the live
+// object so that it is deleted when it is not in the manifest.
+func (m Manifest) managed(kind, name, object string) bool {
+	if builtinObjects[kind][name] {
+		return false
+	}
+	switch kind {
+	case KindUserLists:
+		attrs, err := parseAttributes(object)
+		if err != nil {
+			return false
+		}
+		for _, t := range splitList(attributeMap(attrs)["type"]) {
+			if (t == "ACL" && m.ACLs != nil) || (t == "DEPT" && m.Departments != nil) {
+				return true
+			}
+		}
+		return false
+	case KindProjects:
+		return m.Projects != nil
+	case KindQueues:
+		return m.Queues != nil
+	case KindPEs:
+		return m.PEs != nil
+	}
+	return false
+}
+
+// mergeAttributes replaces the attributes of an object by the given
+// values and sets the name attribute.
+func mergeAttributes(object, nameAttr, name string, set map[string]string) (string, error) {
+	attrs, err := parseAttributes(object)
+	if err != nil {
+		return "", err
+	}
+	values := map[string]string{nameAttr: name}
+	for attr, value := range set {
+		if attr != nameAttr {
+			values[attr] = normalizeValue(value)
+		}
+	}
+	for i, a := range attrs {
+		if value, exists := values[a.name]; exists {
+			attrs[i].value = value
+			delete(values, a.name)
+		}
+	}
+	var added []string
+	for attr := range values {
+		added = append(added, attr)
+	}
+	sort.Strings(added)
+	for _, attr := range added {
+		attrs = append(attrs, attribute{attr, values[attr]})
+	}
+	return formatAttributes(attrs), nil
+}
+
+// manifestKinds are the kinds which are described by a manifest in the
+// order in which they are added.
+var manifestKinds = []string{KindUserLists, KindProjects, KindPEs, KindQueues}
+
+// Plan compares the manifest with the cluster and returns the qconf
+// operations which are required to reach the desired state.
+func (m Manifest) Plan() (Plan, error) {
+	var plan, deletes Plan
+	for _, kind := range manifestKinds {
+		k, err := kindByName(kind)
+		if err != nil {
+			return nil, err
+		}
+		live := make(map[string]string)
+		names, err := qconfList(k.list)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			out, err := qconf(k.show, name)
+			if err != nil {
+				return nil, err
+			}
+			live[name] = string(out)
+		}
+		desired, err := m.desiredObjects(kind, live)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range sortedKeys(desired) {
+			previous, exists := live[name]
+			if !exists {
+				plan = append(plan, PlanStep{Kind: kind, Name: name, Op: PlanAdd, Object: desired[name]})
+				continue
+			}
+			changes, err := diffObject(kind, previous, desired[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s", kind, name, err)
+			}
+			if len(changes) > 0 {
+				plan = append(plan, PlanStep{Kind: kind, Name: name, Op: PlanModify,
+					Object: desired[name], Previous: previous, Changes: changes})
+			}
+		}
+		if !m.Prune {
+			continue
+		}
+		var kindDeletes Plan
+		for _, name := range sortedKeys(live) {
+			if _, exists := desired[name]; !exists && m.managed(kind, name, live[name]) {
+				kindDeletes = append(kindDeletes, PlanStep{Kind: kind, Name: name, Op: PlanDelete,
+					Previous: live[name]})
+			}
+		}
+		deletes = append(kindDeletes, deletes...)
+	}
+	return append(plan, deletes...), nil
+}
+
+// Apply executes the steps of the plan. When a step fails all steps
+// which were applied before are rolled back in reverse order. The
+// returned error contains the failed step and rollback errors.
+func (p Plan) Apply() error {
+	for i, step := range p {
+		if err := step.apply(); err != nil {
+			err = fmt.Errorf("%s %s %s failed: %s", step.Op, step.Kind, step.Name, err)
+			var failed []string
+			for j := i - 1; j >= 0; j-- {
+				if rerr := p[j].rollback(); rerr != nil {
+					failed = append(failed, fmt.Sprintf("%s %s: %s", p[j].Kind, p[j].Name, rerr))
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%s (rollback failed for %s)", err, strings.Join(failed, "; "))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s PlanStep) apply() error {
+	k, err := kindByName(s.Kind)
+	if err != nil {
+		return err
+	}
+	switch s.Op {
+	case PlanAdd:
+		return qconfFromFile(k.add, s.Object)
+	case PlanModify:
+		return qconfFromFile(k.modify, s.Object)
+	case PlanDelete:
+		_, err := qconf(k.remove, s.Name)
+		return err
+	}
+	return fmt.Errorf("unknown operation %s", s.Op)
+}
+
+// rollback reverts an applied step.
+func (s PlanStep) rollback() error {
+	k, err := kindByName(s.Kind)
+	if err != nil {
+		return err
+	}
+	switch s.Op {
+	case PlanAdd:
+		_, err := qconf(k.remove, s.Name)
+		return err
+	case PlanModify:
+		return qconfFromFile(k.modify, s.Previous)
+	case PlanDelete:
+		return qconfFromFile(k.add, s.Previous)
+	}
+	return fmt.Errorf("unknown operation %s", s.Op)
+}
+
+// String returns the plan in a human readable form. Added objects are
+// prefixed by +, modified objects by ~, and deleted objects by -.
+func (p Plan) String() string {
+	var buf bytes.Buffer
+	for _, s := range p {
+		switch s.Op {
+		case PlanAdd:
+			fmt.Fprintf(&buf, "+ %s %s\n", s.Kind, s.Name)
+		case PlanModify:
+			fmt.Fprintf(&buf, "~ %s %s\n", s.Kind, s.Name)
+			writeAttributeChanges(&buf, s.Changes)
+		case PlanDelete:
+			fmt.Fprintf(&buf, "- %s %s\n", s.Kind, s.Name)
+		}
+	}
+	return buf.String()
+}
+
+// kindByName returns the description of a snapshot kind.
+func kindByName(name string) (objectKind, error) {
+	for _, k := range objectKinds {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	return objectKind{}, fmt.Errorf("unknown object kind %s", name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
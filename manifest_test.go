@@ -0,0 +1,174 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testManifest = `{
+  "acls": [
+    {"name": "staff", "entries": ["bob", "alice"]},
+    {"name": "new", "entries": ["carol"]}
+  ],
+  "pes": {"mpi": {"slots": "64"}},
+  "queues": {
+    "all.q": {"slots": "8", "pe_list": "mpi make"},
+    "new.q": {"pe_list": "mpi"}
+  },
+  "prune": true
+}`
+
+func TestManifestPlanApply(t *testing.T) {
+	_, restore := fakeCluster(map[string]string{
+		"-sul":                  "staff\nold\ndefaultdepartment\n",
+		"-su staff":             "name staff\ntype ACL\nfshare 0\noticket 0\nentries alice\n",
+		"-su old":               "name old\ntype ACL\nfshare 0\noticket 0\nentries NONE\n",
+		"-su defaultdepartment": "name defaultdepartment\ntype DEPT\nfshare 0\noticket 0\nentries NONE\n",
+		"-spl":                  "mpi\n",
+		"-sp mpi":               "pe_name mpi\nslots 16\nallocation_rule $fill_up\n",
+		"-sql":                  "all.q\n",
+		"-sq all.q":             "qname all.q\nhostlist @allhosts\npe_list make mpi\nslots 1\n",
+		"-sq":                   "qname template\nhostlist NONE\npe_list make\nslots 1\n",
+	})
+	defer restore()
+
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ACLs[0].Type != "ACL" || m.Departments != nil {
+		t.Errorf("Wrong manifest: %v", m)
+	}
+	plan, err := m.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `+ userlists new
+~ userlists staff
+    ~ entries: alice -> alice,bob
+~ pes mpi
+    ~ slots: 16 -> 64
+~ queues all.q
+    ~ slots: 1 -> 8
++ queues new.q
+- userlists old
+`
+	if got := plan.String(); got != expected {
+		t.Errorf("Wrong plan:\n%s\nexpected\n%s", got, expected)
+	}
+	if obj := plan[4].Object; !strings.Contains(obj, "qname    new.q") || !strings.Contains(obj, "pe_list  mpi") {
+		t.Errorf("New queue must be based on the template:\n%s", obj)
+	}
+
+	// adding new.q fails, hence all previous steps are rolled back
+	var calls []string
+	qconf = func(args ...string) ([]byte, error) {
+		calls = append(calls, args[0])
+		if args[0] == "-Aq" {
+			return nil, errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+	if err := plan.Apply(); err == nil {
+		t.Error("Expected error when adding a queue fails")
+	}
+	if got := strings.Join(calls, ","); got != "-Au,-Mu,-Mp,-Mq,-Aq,-Mq,-Mp,-Mu,-dul" {
+		t.Errorf("Wrong qconf calls during apply and rollback: %s", got)
+	}
+}
+
+func TestPlanApplyUnknownKind(t *testing.T) {
+	var calls []string
+	orig := qconf
+	defer func() { qconf = orig }()
+	qconf = func(args ...string) ([]byte, error) {
+		calls = append(calls, args[0])
+		return nil, nil
+	}
+	plan := Plan{
+		{Kind: KindUserLists, Name: "new", Op: PlanAdd, Object: "name new\ntype ACL\nentries NONE\n"},
+		{Kind: "widgets", Name: "w", Op: PlanAdd},
+	}
+	err := plan.Apply()
+	if err == nil || !strings.Contains(err.Error(), "unknown object kind widgets") {
+		t.Errorf("Expected error for unknown kind but got %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "-Au,-dul" {
+		t.Errorf("Expected rollback of the first step but got qconf calls %s", got)
+	}
+}
+
+var testYAMLManifest = `
+acls:
+  - name: staff
+    entries: [bob, alice]
+  - name: new
+    entries:
+      - carol
+pes:
+  mpi: {slots: 64}
+queues:
+  all.q:
+    slots: 8
+    pe_list: mpi make
+  new.q: {pe_list: mpi}
+prune: true
+`
+
+func TestParseManifestYAML(t *testing.T) {
+	fromJSON, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := ParseManifest([]byte(testYAMLManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("YAML manifest differs from JSON manifest:\n%v\n%v", fromYAML, fromJSON)
+	}
+	if _, err := ParseManifest([]byte("acls: [name: staff")); err == nil {
+		t.Errorf("Expected error for invalid YAML")
+	}
+}
+
+func TestManifestPruneBuiltins(t *testing.T) {
+	_, restore := fakeCluster(map[string]string{
+		"-sul":                  "arusers\ndeadlineusers\ndefaultdepartment\nold\n",
+		"-su arusers":           "name arusers\ntype ACL\nfshare 0\noticket 0\nentries NONE\n",
+		"-su deadlineusers":     "name deadlineusers\ntype ACL\nfshare 0\noticket 0\nentries NONE\n",
+		"-su defaultdepartment": "name defaultdepartment\ntype DEPT\nfshare 0\noticket 0\nentries NONE\n",
+		"-su old":               "name old\ntype DEPT\nfshare 0\noticket 0\nentries NONE\n",
+	})
+	defer restore()
+
+	m, err := ParseManifest([]byte("acls: []\ndepartments: []\nprune: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := m.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 1 || plan[0].Name != "old" || plan[0].Op != PlanDelete {
+		t.Errorf("Only the user list old must be pruned:\n%s", plan)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operation is a class of Grid Engine operations which require a
+// certain permission.
+type Operation int
+
+const (
+	// ManagerOperation is an operation which requires manager rights,
+	// like changing the cluster configuration
+	ManagerOperation Operation = iota
+	// OperatorOperation is an operation which requires operator rights,
+	// like disabling queues or modifying user lists
+	OperatorOperation
+	// SubmitOperation is submitting jobs into the cluster
+	SubmitOperation
+	// ACLOwnerOperation is an operation which is allowed to the members
+	// of a certain user list, like advance reservations (arusers) or
+	// deadline jobs (deadlineusers)
+	ACLOwnerOperation
+)
+
+// String returns the name of the operation class.
+func (o Operation) String() string {
+	switch o {
+	case ManagerOperation:
+		return "manager"
+	case OperatorOperation:
+		return "operator"
+	case SubmitOperation:
+		return "submit"
+	case ACLOwnerOperation:
+		return "acl owner"
+	}
+	return "unknown"
+}
+
+// Permissions contains the lists which determine what a user is allowed
+// to do from which host. AccessLists and XAccessLists are the user_lists
+// and xuser_lists of the global cluster configuration.
+type Permissions struct {
+	Managers     []string
+	Operators    []string
+	AdminHosts   []string
+	SubmitHosts  []string
+	UserLists    []UserList
+	AccessLists  []string
+	XAccessLists []string
+}
+
+// GetPermissions reads the manager, operator, admin host, and submit
+// host lists, all user lists, and the global user_lists and xuser_lists
+// from the cluster.
+func GetPermissions() (*Permissions, error) {
+	var p Permissions
+	var err error
+	if p.Managers, err = GetManagers(); err != nil {
+		return nil, err
+	}
+	if p.Operators, err = GetOperators(); err != nil {
+		return nil, err
+	}
+	if p.AdminHosts, err = GetAdminHosts(); err != nil {
+		return nil, err
+	}
+	if p.SubmitHosts, err = GetSubmitHosts(); err != nil {
+		return nil, err
+	}
+	names, err := GetUserListNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		if p.UserLists, err = GetUserLists(names...); err != nil {
+			return nil, err
+		}
+	}
+	global, err := GetClusterConfig(GlobalConfig)
+	if err != nil {
+		return nil, err
+	}
+	p.AccessLists = splitList(global.Other["user_lists"])
+	p.XAccessLists = splitList(global.Other["xuser_lists"])
+	return &p, nil
+}
+
+// Check returns nil if the user (with its primary UNIX group) is allowed
+// to perform the operation from the host, otherwise an error describing
+// the reason. For ACLOwnerOperation acl is the name of the user list
+// which grants the permission, it is ignored otherwise.
+//
+// Manager operations require the user to be manager, operator operations
+// to be manager or operator, both from an administrative host. Submit
+// operations require a submit host and a user which is allowed by the
+// global user_lists and xuser_lists.
+func (p Permissions) Check(op Operation, user, group, host, acl string) error {
+	switch op {
+	case ManagerOperation, OperatorOperation:
+		if !containsHost(p.AdminHosts, host) {
+			return fmt.Errorf("host %s is not an administrative host", host)
+		}
+		if contains(p.Managers, user) {
+			return nil
+		}
+		if op == OperatorOperation && contains(p.Operators, user) {
+			return nil
+		}
+		return fmt.Errorf("user %s has no %s rights", user, op)
+	case SubmitOperation:
+		if !containsHost(p.SubmitHosts, host) {
+			return fmt.Errorf("host %s is not a submit host", host)
+		}
+		for _, name := range p.XAccessLists {
+			if ul, exists := p.userList(name); exists && ul.Contains(user, group) {
+				return fmt.Errorf("user %s is rejected by user list %s", user, name)
+			}
+		}
+		if len(p.AccessLists) == 0 {
+			return nil
+		}
+		for _, name := range p.AccessLists {
+			if ul, exists := p.userList(name); exists && ul.Contains(user, group) {
+				return nil
+			}
+		}
+		return fmt.Errorf("user %s is not member of any of the user lists %s", user, strings.Join(p.AccessLists, ","))
+	case ACLOwnerOperation:
+		ul, exists := p.userList(acl)
+		if !exists {
+			return fmt.Errorf("user list %s does not exist", acl)
+		}
+		if !ul.Contains(user, group) {
+			return fmt.Errorf("user %s is not member of user list %s", user, acl)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %d", op)
+}
+
+// Allowed returns true if the user is allowed to perform the operation
+// from the host (see Check).
+func (p Permissions) Allowed(op Operation, user, group, host, acl string) bool {
+	return p.Check(op, user, group, host, acl) == nil
+}
+
+func (p Permissions) userList(name string) (UserList, bool) {
+	for _, ul := range p.UserLists {
+		if ul.Name == name {
+			return ul, true
+		}
+	}
+	return UserList{}, false
+}
+
+func contains(list []string, value string) bool {
+	for _, e := range list {
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsHost compares host names case insensitive. When one of the
+// names is not fully qualified only the short names are compared.
+func containsHost(hosts []string, host string) bool {
+	short := func(h string) string {
+		if dot := strings.Index(h, "."); dot >= 0 {
+			return h[:dot]
+		}
+		return h
+	}
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+		if (!strings.Contains(h, ".") || !strings.Contains(host, ".")) && strings.EqualFold(short(h), short(host)) {
+			return true
+		}
+	}
+	return false
+}
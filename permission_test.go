@@ -0,0 +1,66 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+)
+
+func TestPermissionsCheck(t *testing.T) {
+	p := Permissions{
+		Managers:    []string{"root"},
+		Operators:   []string{"daniel"},
+		AdminHosts:  []string{"master.example.com"},
+		SubmitHosts: []string{"login1", "master"},
+		UserLists: []UserList{
+			{Name: "arusers", Type: "ACL", Entries: []string{"alice"}},
+			{Name: "staff", Type: "ACL", Entries: []string{"%staff", "daniel"}},
+			{Name: "banned", Type: "ACL", Entries: []string{"mallory"}},
+		},
+		AccessLists:  []string{"staff"},
+		XAccessLists: []string{"banned"},
+	}
+	tests := []struct {
+		op      Operation
+		user    string
+		group   string
+		host    string
+		acl     string
+		allowed bool
+	}{
+		{ManagerOperation, "root", "root", "master", "", true},
+		{ManagerOperation, "root", "root", "login1", "", false},
+		{ManagerOperation, "daniel", "users", "master.example.com", "", false},
+		{OperatorOperation, "daniel", "users", "master.example.com", "", true},
+		{OperatorOperation, "alice", "staff", "master", "", false},
+		{SubmitOperation, "alice", "staff", "login1.example.com", "", true},
+		{SubmitOperation, "daniel", "users", "login1", "", true},
+		{SubmitOperation, "bob", "users", "login1", "", false},
+		{SubmitOperation, "mallory", "staff", "login1", "", false},
+		{SubmitOperation, "alice", "staff", "node1", "", false},
+		{ACLOwnerOperation, "alice", "staff", "node1", "arusers", true},
+		{ACLOwnerOperation, "bob", "staff", "node1", "arusers", false},
+		{ACLOwnerOperation, "alice", "staff", "node1", "missing", false},
+	}
+	for _, test := range tests {
+		err := p.Check(test.op, test.user, test.group, test.host, test.acl)
+		if (err == nil) != test.allowed {
+			t.Errorf("%s operation of %s on %s: expected allowed=%v but got %v",
+				test.op, test.user, test.host, test.allowed, err)
+		}
+	}
+}
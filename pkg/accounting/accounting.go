@@ -28,7 +28,8 @@ import (
 
 // Entry is one accounting entry line of the accounting file. Multiple
 // entries can for a job when it is a parallel job and the accounting is
-// configured to have for each task an accounting entry.
+// configured to have for each task an accounting entry. The times
+// RuWallclock, RuUtime, and RuStime are seconds with fractions.
 type Entry struct {
 	Qname                            string
 	Hostname                         string
@@ -43,9 +44,9 @@ type Entry struct {
 	EndTime                          time.Time
 	Failed                           int
 	ExitStatus                       int
-	RuWallclock                      int64
-	RuUtime                          int64
-	RuStime                          int64
+	RuWallclock                      float64
+	RuUtime                          float64
+	RuStime                          float64
 	RuMaxRSS                         int64
 	RuIxRSS                          int64
 	RuIsmRSS                         int64
@@ -83,41 +84,94 @@ type Entry struct {
 	CurrentWorkingDirectory          string
 	SubmitCommand                    string
 	WallClock                        float64 // milliseconds.<>
+
+	// The following fields are not columns of the accounting file.
+
+	// RawPETaskID is the pe_taskid column as written by Grid Engine
+	// (like NONE or 1.node1). PETaskID is only its number.
+	RawPETaskID string
 }
 
+// peTaskIDColumn is the index of the pe_taskid column.
+const peTaskIDColumn = 41
+
 func sEpochTime(s int64) time.Time {
 	return time.Unix(s, 0)
 }
 
 func msEpochTime(ms int64) time.Time {
 	sec := ms / 1000
-	nsec := (ms % 1000) * 1000
+	nsec := (ms % 1000) * int64(time.Millisecond)
 	return time.Unix(sec, nsec)
 }
 
-func parseTime(column []byte) time.Time {
-	timeString := string(column)
-	timeInt, err := strconv.ParseInt(timeString, 10, 64)
+// parseTime converts a time stamp in seconds or milliseconds since
+// epoch. With ms set the time stamp is always in milliseconds, otherwise
+// the unit is guessed by its magnitude.
+func parseTime(column []byte, ms bool) (time.Time, error) {
+	timeInt, err := strconv.ParseInt(string(column), 10, 64)
 	if err != nil {
-		return time.Unix(0, 0)
+		return time.Unix(0, 0), err
 	}
 	// we assume here that all records dates are after 1990.
-	if timeInt > 631152000000 {
+	if ms || timeInt > 631152000000 {
 		// it mus be a ms time-stamp after 1990
-		return msEpochTime(timeInt)
+		return msEpochTime(timeInt), nil
 	}
-	return sEpochTime(timeInt)
+	return sEpochTime(timeInt), nil
 }
 
-// ParseLine reads a line of the Grid Engine accounting file
-// and returns a parsed Entry structure.
-func ParseLine(line []byte) (Entry, error) {
+// parseInt converts an integer column. Values which are written with
+// fractions are truncated. NONE (like the pe_taskid of sequential jobs)
+// is 0, for PE task IDs like 1.node1 the number is returned.
+func parseInt(column []byte) (int64, error) {
+	if string(column) == "NONE" {
+		return 0, nil
+	}
+	if dot := bytes.IndexByte(column, '.'); dot > 0 && bytes.IndexFunc(column[dot+1:], isLetter) >= 0 {
+		column = column[:dot]
+	}
+	i, err := strconv.ParseInt(string(column), 10, 64)
+	if err == nil {
+		return i, nil
+	}
+	f, ferr := strconv.ParseFloat(string(column), 64)
+	if ferr != nil {
+		return 0, err
+	}
+	return int64(f), nil
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// splitLine splits a line into its columns.
+func splitLine(line []byte) [][]byte {
 	// split at ":" (strings are utf-8 hence we are on byte level)
 	chunks := bytes.Split(line, []byte(":"))
 	for i := range chunks {
 		// replace special character to ":" within a chunk
-		chunks[i] = bytes.Replace(chunks[i], []byte("\xFF"), []byte(":"), 0)
+		chunks[i] = bytes.Replace(chunks[i], []byte("\xFF"), []byte(":"), -1)
 	}
+	return chunks
+}
+
+// ParseLine reads a line of the Grid Engine accounting file
+// and returns a parsed Entry structure. Values which can't be
+// converted are ignored (left zero). Use Schema.ParseLine for
+// strict parsing and a Parser for parsing many lines.
+func ParseLine(line []byte) (Entry, error) {
+	var p Parser
+	var e Entry
+	err := p.Parse(line, &e)
+	return e, err
+}
+
+// parseColumns converts the columns into an Entry using reflection. In
+// strict mode the first value which can't be converted is returned as
+// ColumnError. Otherwise the result is the same as of a Parser.
+func parseColumns(chunks [][]byte, strict, ms bool) (Entry, error) {
 	var e Entry
 	v := reflect.ValueOf(&e).Elem()
 
@@ -126,33 +180,40 @@ func ParseLine(line []byte) (Entry, error) {
 	// the struct. That means the order in the Element struct
 	// definition must be aligned with the column order in
 	// the accounting field. Scanning ends when there is no
-	// more column or no more field in the struct, so it is
+	// more column or no more column field in the struct, so it is
 	// save it use it also with older versions of the accounting
 	// file. The only thing which needs to be taken care of
 	// is that Univa Grid Engine 8.2 introduced ms since epoch
 	// instead of seconds since epoch as date fields But this
 	// is also recogized by the magnitude of the number.
-	for i := 0; i < v.NumField() && i < len(chunks); i++ {
+	for i := 0; i < len(columnNames) && i < len(chunks); i++ {
 		field := v.Field(i)
+		var err error
+		if i == peTaskIDColumn {
+			e.RawPETaskID = string(chunks[i])
+		}
 		switch field.Kind() {
 		case reflect.String:
 			field.SetString(string(chunks[i]))
-		case reflect.Int64:
-			i64val, _ := strconv.ParseInt(string(chunks[i]), 10, 64)
-			field.SetInt(i64val)
-		case reflect.Int:
-			ival, _ := strconv.Atoi(string(chunks[i]))
-			field.SetInt(int64(ival))
+		case reflect.Int64, reflect.Int:
+			var ival int64
+			ival, err = parseInt(chunks[i])
+			field.SetInt(ival)
 		case reflect.Float64:
-			fl, _ := strconv.ParseFloat(string(chunks[i]), 64)
+			var fl float64
+			fl, err = strconv.ParseFloat(string(chunks[i]), 64)
 			field.SetFloat(fl)
 		case reflect.Struct:
 			// check if it is a Time (we trust it is from time package)
 			if field.Type().Name() == "Time" {
-				t := parseTime(chunks[i])
+				var t time.Time
+				t, err = parseTime(chunks[i], ms)
 				field.Set(reflect.ValueOf(t))
 			}
 		}
+		if err != nil && strict {
+			return e, &ColumnError{Column: i + 1, Name: columnNames[i], Value: string(chunks[i]), Err: err}
+		}
 	}
 	return e, nil
 }
@@ -0,0 +1,286 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"github.com/dgruber/ugego/Godeps/_workspace/src/github.com/ulikunitz/xz"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// MultiReader reads the entries of a sequence of accounting files (like
+// rotated and compressed archives of the accounting file) as one stream.
+// Plain, gzip, bzip2, and xz compressed files are supported, the
+// compression is detected from the content of the file.
+//
+//	r, err := accounting.OpenGlob("/archive/accounting*")
+//	...
+//	defer r.Close()
+//	for r.Next() {
+//	    e, origin := r.Record()
+//	    ...
+//	}
+//	if err := r.Err(); err != nil {
+//	    ...
+//	}
+type MultiReader struct {
+	// MaxLineLength and Strict are passed to the Reader of each file.
+	// The schema is detected for each file.
+	MaxLineLength int
+	Strict        bool
+
+	files   []string
+	current int
+	file    io.Closer
+	r       *Reader
+	err     error
+}
+
+// OpenFiles returns a MultiReader for the accounting files. The files
+// are ordered chronologically by the end time of their first entry,
+// files without entries by their modification time.
+func OpenFiles(files ...string) (*MultiReader, error) {
+	type start struct {
+		file string
+		time time.Time
+	}
+	starts := make([]start, 0, len(files))
+	for _, file := range files {
+		t, err := firstEndTime(file)
+		if err != nil {
+			return nil, err
+		}
+		starts = append(starts, start{file: file, time: t})
+	}
+	sort.SliceStable(starts, func(i, j int) bool {
+		return starts[i].time.Before(starts[j].time)
+	})
+	m := &MultiReader{files: make([]string, 0, len(starts)), current: -1}
+	for _, s := range starts {
+		m.files = append(m.files, s.file)
+	}
+	return m, nil
+}
+
+// OpenGlob returns a MultiReader for the accounting files which match
+// the pattern (see filepath.Match) in chronological order.
+func OpenGlob(pattern string) (*MultiReader, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no accounting files match %s", pattern)
+	}
+	return OpenFiles(files...)
+}
+
+// Files returns the files in the order in which they are read.
+func (m *MultiReader) Files() []string {
+	return m.files
+}
+
+// Next reads the next entry. It continues with the next file at the end
+// of a file. It returns false when there are no more entries or an error
+// occurred (see Err).
+func (m *MultiReader) Next() bool {
+	if m.err != nil {
+		return false
+	}
+	for {
+		if m.r != nil {
+			if m.r.Next() {
+				return true
+			}
+			if err := m.r.Err(); err != nil {
+				m.err = fmt.Errorf("%s: %s", m.File(), err)
+			}
+			m.file.Close()
+			m.file, m.r = nil, nil
+			if m.err != nil {
+				return false
+			}
+		}
+		if m.current+1 >= len(m.files) {
+			return false
+		}
+		m.current++
+		f, err := openAccountingFile(m.files[m.current])
+		if err != nil {
+			m.err = err
+			return false
+		}
+		m.file = f
+		m.r = NewReader(f)
+		m.r.MaxLineLength = m.MaxLineLength
+		m.r.Strict = m.Strict
+	}
+}
+
+// Entry returns the entry which was read by the last call of Next.
+func (m *MultiReader) Entry() Entry {
+	if m.r == nil {
+		return Entry{}
+	}
+	return m.r.Entry()
+}
+
+// Text returns the line of the entry which was read last without line
+// ending. It is valid until the next call of Next.
+func (m *MultiReader) Text() []byte {
+	if m.r == nil {
+		return nil
+	}
+	return m.r.Text()
+}
+
+// Origin is the location of an entry within the accounting files.
+type Origin struct {
+	File string
+	// Line is the line number within File starting with 1. For
+	// compressed files it is the line of the uncompressed content.
+	Line int
+}
+
+// Record returns the entry which was read by the last call of Next
+// together with its origin.
+func (m *MultiReader) Record() (Entry, Origin) {
+	return m.Entry(), Origin{File: m.File(), Line: m.Line()}
+}
+
+// File returns the file of the entry which was read last.
+func (m *MultiReader) File() string {
+	if m.current < 0 || m.current >= len(m.files) {
+		return ""
+	}
+	return m.files[m.current]
+}
+
+// Line returns the line number within File of the entry which was read
+// last. For compressed files it is the line of the uncompressed content.
+func (m *MultiReader) Line() int {
+	if m.r == nil {
+		return 0
+	}
+	return m.r.Line()
+}
+
+// Err returns the error which stopped the MultiReader, or nil when the
+// end of the last file was reached.
+func (m *MultiReader) Err() error {
+	return m.err
+}
+
+// Close closes the file which is currently read.
+func (m *MultiReader) Close() error {
+	m.current = len(m.files)
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file, m.r = nil, nil
+	return err
+}
+
+// OpenFile opens a plain, gzip, bzip2, or xz compressed accounting file and
+// returns its uncompressed content.
+func OpenFile(file string) (io.ReadCloser, error) {
+	return openAccountingFile(file)
+}
+
+type decompressedFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressedFile) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func openAccountingFile(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(len(xzMagic))
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		return &decompressedFile{Reader: gz, closers: []io.Closer{gz, f}}, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return &decompressedFile{Reader: bzip2.NewReader(br), closers: []io.Closer{f}}, nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		return &decompressedFile{Reader: xr, closers: []io.Closer{f}}, nil
+	}
+	return &decompressedFile{Reader: br, closers: []io.Closer{f}}, nil
+}
+
+// firstEndTime returns the end time of the first entry of the file or,
+// when the file has no entries, its modification time.
+func firstEndTime(file string) (time.Time, error) {
+	f, err := openAccountingFile(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	r := NewReader(f)
+	if r.Next() {
+		return r.Entry().EndTime, nil
+	}
+	if err := r.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %s", file, err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
@@ -0,0 +1,183 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/dgruber/ugego/Godeps/_workspace/src/github.com/ulikunitz/xz"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveContent returns an accounting file with the jobs which ended
+// on consecutive days starting with the day.
+func archiveContent(t *testing.T, first int, day time.Time, jobs int) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Version = "8.4.0"
+	for i := 0; i < jobs; i++ {
+		e := Entry{Qname: "all.q", Hostname: "node1", Owner: "alice",
+			JobNumber: strconv.Itoa(first + i), EndTime: day.AddDate(0, 0, i)}
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestOpenGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := map[string][]byte{
+		"accounting":      archiveContent(t, 7, jan.AddDate(0, 2, 0), 3),
+		"accounting.1.gz": gzipped(archiveContent(t, 4, jan.AddDate(0, 1, 0), 3)),
+		"accounting.2.gz": gzipped(archiveContent(t, 1, jan, 3)),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := OpenGlob(filepath.Join(dir, "accounting*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	job := 1
+	for r.Next() {
+		if r.Entry().JobNumber != strconv.Itoa(job) {
+			t.Errorf("Expected job %d but got %s from %s", job, r.Entry().JobNumber, r.File())
+		}
+		// 4 header lines
+		if expected := 5 + (job-1)%3; r.Line() != expected {
+			t.Errorf("Expected line %d but got %d", expected, r.Line())
+		}
+		job++
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+	if job != 10 {
+		t.Errorf("Expected 9 entries but got %d", job-1)
+	}
+	if !strings.HasSuffix(r.Files()[0], "accounting.2.gz") {
+		t.Errorf("Wrong file order: %v", r.Files())
+	}
+	if _, err := OpenGlob(filepath.Join(dir, "missing*")); err == nil {
+		t.Errorf("Expected error when no file matches")
+	}
+}
+
+func TestOpenFilesBzip2(t *testing.T) {
+	bzip2, err := exec.LookPath("bzip2")
+	if err != nil {
+		t.Skip("bzip2 is not installed")
+	}
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "accounting.1")
+	data := archiveContent(t, 1, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 2)
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(bzip2, file).CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	r, err := OpenFiles(file + ".bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	count := 0
+	for r.Next() {
+		count++
+	}
+	if r.Err() != nil || count != 2 {
+		t.Errorf("Expected 2 entries but got %d (%v)", count, r.Err())
+	}
+}
+
+func TestOpenFilesXZ(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(archiveContent(t, 1, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 2))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "accounting.1.xz")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenFiles(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var origins []Origin
+	for r.Next() {
+		e, origin := r.Record()
+		if e.JobNumber != strconv.Itoa(len(origins)+1) {
+			t.Errorf("Wrong entry %s from %v", e.JobNumber, origin)
+		}
+		origins = append(origins, origin)
+	}
+	if r.Err() != nil || len(origins) != 2 {
+		t.Fatalf("Expected 2 entries but got %d (%v)", len(origins), r.Err())
+	}
+	if origins[0] != (Origin{File: file, Line: 5}) || origins[1] != (Origin{File: file, Line: 6}) {
+		t.Errorf("Wrong origins: %v", origins)
+	}
+
+	broken := filepath.Join(dir, "accounting.2.xz")
+	if err := ioutil.WriteFile(broken, append(xzMagic, 0, 0, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFiles(broken); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("Expected error for broken xz file but got %v", err)
+	}
+}
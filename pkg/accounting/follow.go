@@ -0,0 +1,301 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/dgruber/ugego/Godeps/_workspace/src/gopkg.in/fsnotify.v0"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// followPollInterval is the interval in which a followed file is
+	// checked for changes with Poll. Without Poll it is the fallback
+	// for missed inotify events.
+	followPollInterval     = 250 * time.Millisecond
+	followFallbackInterval = time.Second
+	// followHeadSize is the number of leading bytes of the file which
+	// are compared to detect that it was truncated and written again.
+	followHeadSize = 4096
+)
+
+// FollowOptions configure how an accounting file is followed.
+type FollowOptions struct {
+	// FromStart emits the entries which are already in the file.
+	// Otherwise only entries which are appended are emitted.
+	FromStart bool
+	// Poll checks the file periodically for changes instead of using
+	// inotify (required for NFS mounted spool directories).
+	Poll bool
+}
+
+// Follower tails a live accounting file and emits the parsed entries
+// of appended lines. Comments and lines which can't be parsed are
+// skipped.
+//
+// The file is reopened and read from the start when it was rotated or
+// truncated. A rotation (the file was moved or removed and created
+// again) is detected by the identity of the file (device and inode).
+// A truncation is detected when the file is smaller than the data
+// which was read or when its first 4 KiB differ from the data which
+// was read. Hence a truncation is missed when, between two checks,
+// the file is truncated and written again with at least as many bytes
+// as were read and with the same first 4 KiB. The entries written
+// before the previous end of the file are lost then.
+type Follower struct {
+	entries chan Entry
+	file    string
+	err     error
+	// reopened receives a value (when it is not full) each time the
+	// file is reopened after a rotation or truncation.
+	reopened chan struct{}
+
+	f *os.File
+	r *bufio.Reader
+	// offset is the number of bytes read from f, head the first bytes
+	// of them, and partial an incomplete last line.
+	offset  int64
+	head    []byte
+	partial []byte
+}
+
+// Follow starts following the accounting file. With FromStart the file
+// does not need to exist yet. The follower stops and closes the Entries
+// channel when the context is done.
+func Follow(ctx context.Context, file string, opts FollowOptions) (*Follower, error) {
+	f := &Follower{
+		entries:  make(chan Entry, 1024),
+		file:     file,
+		reopened: make(chan struct{}, 16),
+	}
+	fh, err := os.Open(file)
+	if err != nil && (!opts.FromStart || !os.IsNotExist(err)) {
+		return nil, err
+	}
+	if fh != nil {
+		if err := f.open(fh, !opts.FromStart); err != nil {
+			fh.Close()
+			return nil, err
+		}
+	}
+	var w *fsnotify.Watcher
+	interval := followPollInterval
+	if !opts.Poll {
+		if w, err = fsnotify.NewWatcher(); err != nil {
+			f.close()
+			return nil, err
+		}
+		// the directory is watched as the file itself can be replaced
+		if err := w.Watch(filepath.Dir(file)); err != nil {
+			w.Close()
+			f.close()
+			return nil, err
+		}
+		interval = followFallbackInterval
+	}
+	go f.run(ctx, w, interval)
+	return f, nil
+}
+
+// Entries returns the channel of the parsed accounting entries. It is
+// closed when the follower stops.
+func (f *Follower) Entries() <-chan Entry {
+	return f.entries
+}
+
+// Err returns the error which stopped the follower. It must be called
+// after the Entries channel is closed. Stopping by the context is not
+// an error.
+func (f *Follower) Err() error {
+	return f.err
+}
+
+func (f *Follower) run(ctx context.Context, w *fsnotify.Watcher, interval time.Duration) {
+	defer close(f.entries)
+	defer f.close()
+	var events <-chan *fsnotify.FileEvent
+	var errs <-chan error
+	if w != nil {
+		defer w.Close()
+		events, errs = w.Event, w.Error
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := f.read(ctx); err != nil {
+			f.err = err
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		changed, err := f.changed()
+		if err != nil {
+			f.err = err
+			return
+		}
+		if changed {
+			if err := f.reopen(); err != nil {
+				f.err = err
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-events:
+		case err := <-errs:
+			f.err = err
+			return
+		}
+	}
+}
+
+// open starts reading the file at its start or, with atEnd, at its end.
+func (f *Follower) open(fh *os.File, atEnd bool) error {
+	f.f, f.r = fh, bufio.NewReader(fh)
+	f.offset, f.head, f.partial = 0, nil, nil
+	if !atEnd {
+		return nil
+	}
+	offset, err := fh.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+	f.offset = offset
+	size := followHeadSize
+	if offset < int64(size) {
+		size = int(offset)
+	}
+	head := make([]byte, size)
+	n, err := fh.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	f.head = head[:n]
+	return nil
+}
+
+// reopen opens the file again and reads it from the start. A missing
+// file is opened when it is created.
+func (f *Follower) reopen() error {
+	f.close()
+	fh, err := os.Open(f.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.open(fh, false); err != nil {
+		return err
+	}
+	select {
+	case f.reopened <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (f *Follower) close() {
+	if f.f != nil {
+		f.f.Close()
+		f.f, f.r = nil, nil
+	}
+}
+
+// read emits the entries of the complete lines up to the end of the
+// file.
+func (f *Follower) read(ctx context.Context) error {
+	if f.r == nil {
+		return nil
+	}
+	for {
+		data, err := f.r.ReadBytes('\n')
+		f.offset += int64(len(data))
+		if missing := followHeadSize - len(f.head); missing > 0 {
+			f.head = append(f.head, data[:minInt(missing, len(data))]...)
+		}
+		if err == io.EOF {
+			f.partial = append(f.partial, data...)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(f.partial) > 0 {
+			data = append(f.partial, data...)
+			f.partial = nil
+		}
+		line := bytes.TrimRight(data, "\r\n")
+		if len(bytes.TrimSpace(line)) == 0 || line[0] == '#' {
+			continue
+		}
+		e, err := ParseLine(line)
+		if err != nil {
+			continue
+		}
+		select {
+		case f.entries <- e:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// changed returns true when the file was created, rotated, or
+// truncated since it was opened.
+func (f *Follower) changed() (bool, error) {
+	fi, err := os.Stat(f.file)
+	if os.IsNotExist(err) {
+		// rotated but not created again (yet)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if f.f == nil {
+		return true, nil
+	}
+	current, err := f.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(fi, current) || current.Size() < f.offset {
+		return true, nil
+	}
+	head := make([]byte, len(f.head))
+	n, err := f.f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return !bytes.Equal(head[:n], f.head), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,122 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func jobLine(job string) string {
+	return strings.Replace(testline1, ":1:sge:", ":"+job+":sge:", 1) + "\n"
+}
+
+func appendLine(t *testing.T, file, line string) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectJob(t *testing.T, f *Follower, job string) {
+	select {
+	case e := <-f.Entries():
+		if e.JobNumber != job {
+			t.Errorf("Expected job %s but got %s", job, e.JobNumber)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout while waiting for job %s", job)
+	}
+}
+
+func waitReopen(t *testing.T, f *Follower) {
+	select {
+	case <-f.reopened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout while waiting for the file to be reopened")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	t.Run("poll", func(t *testing.T) { testFollow(t, true) })
+	t.Run("inotify", func(t *testing.T) { testFollow(t, false) })
+}
+
+func testFollow(t *testing.T, poll bool) {
+	dir, err := ioutil.TempDir("", "ugego_follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "accounting")
+	appendLine(t, file, "# Version: 8.3.1\n"+jobLine("1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := Follow(ctx, file, FollowOptions{FromStart: true, Poll: poll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectJob(t, f, "1")
+	appendLine(t, file, "\n"+jobLine("2"))
+	expectJob(t, f, "2")
+
+	// rotation
+	if err := os.Rename(file, file+".0"); err != nil {
+		t.Fatal(err)
+	}
+	appendLine(t, file, jobLine("3"))
+	waitReopen(t, f)
+	expectJob(t, f, "3")
+
+	// truncation
+	if err := os.Truncate(file, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitReopen(t, f)
+	appendLine(t, file, jobLine("4"))
+	expectJob(t, f, "4")
+
+	// truncation and rewrite with the same size before the next check
+	if err := os.Truncate(file, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendLine(t, file, jobLine("5"))
+	waitReopen(t, f)
+	expectJob(t, f, "5")
+
+	cancel()
+	select {
+	case _, open := <-f.Entries():
+		if open {
+			t.Error("Expected no more entries after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Entries channel was not closed after cancel")
+	}
+	if f.Err() != nil {
+		t.Errorf("Stopping by context must not be an error: %s", f.Err())
+	}
+}
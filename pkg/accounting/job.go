@@ -0,0 +1,279 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Task is an array task of a job (or the job itself when it is not an
+// array job) with the entries of its tightly integrated PE subtasks.
+type Task struct {
+	// TaskNumber is 0 when the job is not an array job.
+	TaskNumber int
+	// Master is the entry of the master task, nil when it was not seen
+	// (yet). When the task ran more than once it is the last run.
+	Master *Entry
+	// Runs are the master entries of all runs of the task in the order
+	// they were added. A task has multiple runs when it was rescheduled.
+	Runs []Entry
+	// Subtasks are the entries of the PE subtasks of all runs ordered by
+	// PETaskID and RawPETaskID.
+	Subtasks []Entry
+	// Usage of the master task and all subtasks
+	Usage Usage
+}
+
+// Job merges the accounting entries of a job. Array jobs and tightly
+// integrated parallel jobs have multiple entries.
+type Job struct {
+	JobNumber      string
+	SubmissionTime time.Time
+	// Tasks are ordered by TaskNumber.
+	Tasks []*Task
+	// Total is the usage of all tasks.
+	Total Usage
+}
+
+// Master returns the master entry of the first task. It is the entry
+// with the attributes of the job (like owner, project, and queue).
+func (j *Job) Master() Entry {
+	for _, t := range j.Tasks {
+		if e, exists := t.entry(); exists {
+			return e
+		}
+	}
+	return Entry{}
+}
+
+// IsArray returns true when the job is an array job.
+func (j *Job) IsArray() bool {
+	return len(j.Tasks) > 1 || (len(j.Tasks) == 1 && j.Tasks[0].TaskNumber != 0)
+}
+
+// Task returns the array task with the number.
+func (j *Job) Task(number int) (*Task, bool) {
+	i := sort.Search(len(j.Tasks), func(i int) bool { return j.Tasks[i].TaskNumber >= number })
+	if i < len(j.Tasks) && j.Tasks[i].TaskNumber == number {
+		return j.Tasks[i], true
+	}
+	return nil, false
+}
+
+// Add adds an entry of the job. The entry is the master task when it
+// has no PE task id, otherwise a subtask. Subtasks are identified by
+// their raw PE task id (like 1.node1). Repeated entries of the master
+// task or of a subtask (like of a rescheduled job) are kept and their
+// usage is counted since each run used the resources. Only an exact
+// duplicate of an entry which was added before is ignored.
+func (j *Job) Add(e Entry) {
+	t, exists := j.Task(e.TaskNumber)
+	if !exists {
+		t = &Task{TaskNumber: e.TaskNumber}
+		i := sort.Search(len(j.Tasks), func(i int) bool { return j.Tasks[i].TaskNumber >= e.TaskNumber })
+		j.Tasks = append(j.Tasks, nil)
+		copy(j.Tasks[i+1:], j.Tasks[i:])
+		j.Tasks[i] = t
+	}
+	if id := peTaskKey(e); id == "" {
+		for _, run := range t.Runs {
+			if run == e {
+				return
+			}
+		}
+		t.Runs = append(t.Runs, e)
+		t.Master = &e
+	} else {
+		// subtasks with the same id are kept in the order they were added
+		i := sort.Search(len(t.Subtasks), func(i int) bool {
+			s := t.Subtasks[i]
+			return s.PETaskID > e.PETaskID || (s.PETaskID == e.PETaskID && peTaskKey(s) > id)
+		})
+		for k := i - 1; k >= 0 && peTaskKey(t.Subtasks[k]) == id; k-- {
+			if t.Subtasks[k] == e {
+				return
+			}
+		}
+		t.Subtasks = append(t.Subtasks, Entry{})
+		copy(t.Subtasks[i+1:], t.Subtasks[i:])
+		t.Subtasks[i] = e
+	}
+	t.Usage.Add(e)
+	j.Total.Add(e)
+}
+
+// peTaskKey returns the raw PE task id of a subtask or an empty string
+// for a master task.
+func peTaskKey(e Entry) string {
+	switch {
+	case e.RawPETaskID == "NONE":
+		return ""
+	case e.RawPETaskID != "":
+		return e.RawPETaskID
+	case e.PETaskID == 0:
+		return ""
+	}
+	return strconv.Itoa(e.PETaskID)
+}
+
+// entry returns the master entry or, without master, the first subtask.
+func (t *Task) entry() (Entry, bool) {
+	if t.Master != nil {
+		return *t.Master, true
+	}
+	if len(t.Subtasks) > 0 {
+		return t.Subtasks[0], true
+	}
+	return Entry{}, false
+}
+
+// String renders the job like qacct -j. Each array task is printed as
+// one block with the attributes of its master task and the usage of
+// the master task and all PE subtasks.
+func (j *Job) String() string {
+	var buf bytes.Buffer
+	for _, t := range j.Tasks {
+		e, _ := t.entry()
+		buf.WriteString("==============================================================\n")
+		line := func(name string, value interface{}) {
+			fmt.Fprintf(&buf, "%-13s%v\n", name, value)
+		}
+		line("qname", e.Qname)
+		line("hostname", e.Hostname)
+		line("group", e.GroupID)
+		line("owner", e.Owner)
+		line("project", e.Project)
+		line("department", e.Department)
+		line("jobname", e.JobName)
+		line("jobnumber", j.JobNumber)
+		line("taskid", undefinedIfZero(t.TaskNumber))
+		line("account", e.Account)
+		line("priority", e.PosixPriority)
+		line("qsub_time", formatJobTime(e.SubmissionTime))
+		line("start_time", formatJobTime(e.StartTime))
+		line("end_time", formatJobTime(e.EndTime))
+		line("granted_pe", e.GrantedPE)
+		line("slots", e.Slots)
+		line("pe_tasks", len(t.Subtasks))
+		line("failed", e.Failed)
+		line("exit_status", e.ExitStatus)
+		line("ru_wallclock", strconv.FormatFloat(t.Usage.WallClock, 'f', -1, 64))
+		line("ru_utime", fmt.Sprintf("%.3fs", t.Usage.UTime))
+		line("ru_stime", fmt.Sprintf("%.3fs", t.Usage.STime))
+		line("cpu", fmt.Sprintf("%.3fs", t.Usage.CPU))
+		line("mem", fmt.Sprintf("%.3fGBs", t.Usage.Memory))
+		line("io", fmt.Sprintf("%.3fGB", t.Usage.IO))
+		line("iow", fmt.Sprintf("%.3fs", t.Usage.IOW))
+		line("maxvmem", maxVmem(t))
+		line("arid", undefinedIfZero(e.AdvanceReservationID))
+	}
+	return buf.String()
+}
+
+// maxVmem returns the maximum virtual memory of all runs of the master
+// task and the subtasks.
+func maxVmem(t *Task) int64 {
+	var max int64
+	for _, e := range t.Runs {
+		if e.MaxVmem > max {
+			max = e.MaxVmem
+		}
+	}
+	for _, e := range t.Subtasks {
+		if e.MaxVmem > max {
+			max = e.MaxVmem
+		}
+	}
+	return max
+}
+
+func undefinedIfZero(i int) string {
+	if i == 0 {
+		return "undefined"
+	}
+	return strconv.Itoa(i)
+}
+
+func formatJobTime(t time.Time) string {
+	if t.IsZero() || t.Unix() == 0 {
+		return "-/-"
+	}
+	return t.Format(time.ANSIC)
+}
+
+// jobKey identifies a job. Job numbers are reused after they wrapped
+// around, hence the submission time is part of the key.
+type jobKey struct {
+	number    string
+	submitted int64
+}
+
+// JobCollector groups accounting entries by job number and submission
+// time.
+type JobCollector struct {
+	jobs map[jobKey]*Job
+	// latest is the last added job of each job number.
+	latest map[string]*Job
+	order  []*Job
+}
+
+// NewJobCollector returns an empty JobCollector.
+func NewJobCollector() *JobCollector {
+	return &JobCollector{jobs: make(map[jobKey]*Job), latest: make(map[string]*Job)}
+}
+
+// Add adds the entry to its job and returns the job. Entries with the
+// same job number but a different submission time belong to different
+// jobs.
+func (c *JobCollector) Add(e Entry) *Job {
+	key := jobKey{number: e.JobNumber, submitted: e.SubmissionTime.UnixNano()}
+	j, exists := c.jobs[key]
+	if !exists {
+		j = &Job{JobNumber: e.JobNumber, SubmissionTime: e.SubmissionTime}
+		c.jobs[key] = j
+		c.order = append(c.order, j)
+	}
+	c.latest[e.JobNumber] = j
+	j.Add(e)
+	return j
+}
+
+// Job returns the job with the job number. When the job number was used
+// by multiple jobs it is the job of the entry which was added last.
+func (c *JobCollector) Job(number string) (*Job, bool) {
+	j, exists := c.latest[number]
+	return j, exists
+}
+
+// Jobs returns the jobs in the order of their first entry.
+func (c *JobCollector) Jobs() []*Job {
+	return c.order
+}
+
+// Jobs groups the entries by job number and submission time. The jobs
+// are in the order of their first entry.
+func Jobs(entries []Entry) []*Job {
+	c := NewJobCollector()
+	for _, e := range entries {
+		c.Add(e)
+	}
+	return c.Jobs()
+}
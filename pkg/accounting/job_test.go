@@ -0,0 +1,122 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var jobEntries = []Entry{
+	{JobNumber: "10", Owner: "alice", TaskNumber: 2, RuWallclock: 5, CPU: 1},
+	{JobNumber: "11", Owner: "bob", GrantedPE: "mpi", Slots: 3, PETaskID: 2, RuWallclock: 7, CPU: 2, MaxVmem: 300},
+	{JobNumber: "10", Owner: "alice", TaskNumber: 1, RuWallclock: 3, CPU: 1},
+	{JobNumber: "11", Owner: "bob", GrantedPE: "mpi", Slots: 3, RuWallclock: 8, CPU: 0.5, MaxVmem: 100},
+	{JobNumber: "11", Owner: "bob", GrantedPE: "mpi", Slots: 3, PETaskID: 1, RuWallclock: 7, CPU: 2, MaxVmem: 200},
+	{JobNumber: "12", Owner: "carol"},
+}
+
+func TestJobs(t *testing.T) {
+	jobs := Jobs(jobEntries)
+	if len(jobs) != 3 || jobs[0].JobNumber != "10" || jobs[1].JobNumber != "11" {
+		t.Fatalf("Wrong jobs: %v", jobs)
+	}
+	array := jobs[0]
+	if !array.IsArray() || len(array.Tasks) != 2 || array.Tasks[0].TaskNumber != 1 {
+		t.Errorf("Wrong array tasks: %+v", array.Tasks)
+	}
+	if array.Total.Entries != 2 || array.Total.WallClock != 8 {
+		t.Errorf("Wrong array job total: %+v", array.Total)
+	}
+	if task, exists := array.Task(2); !exists || task.Usage.WallClock != 5 {
+		t.Errorf("Task 2 not found")
+	}
+
+	pe := jobs[1]
+	if pe.IsArray() || len(pe.Tasks) != 1 {
+		t.Fatalf("Expected one task: %+v", pe.Tasks)
+	}
+	task := pe.Tasks[0]
+	if task.Master == nil || task.Master.CPU != 0.5 {
+		t.Errorf("Wrong master task: %+v", task.Master)
+	}
+	if len(task.Subtasks) != 2 || task.Subtasks[0].PETaskID != 1 {
+		t.Errorf("Wrong subtasks: %+v", task.Subtasks)
+	}
+	if pe.Total.Entries != 3 || pe.Total.CPU != 4.5 || pe.Master().Owner != "bob" {
+		t.Errorf("Wrong PE job total: %+v", pe.Total)
+	}
+	if jobs[2].IsArray() || jobs[2].Master().Owner != "carol" {
+		t.Errorf("Wrong single job: %+v", jobs[2])
+	}
+}
+
+func TestJobPETaskIDs(t *testing.T) {
+	var j Job
+	j.Add(Entry{JobNumber: "20", RawPETaskID: "NONE", CPU: 1})
+	j.Add(Entry{JobNumber: "20", PETaskID: 1, RawPETaskID: "1.nodeB", CPU: 2})
+	j.Add(Entry{JobNumber: "20", PETaskID: 1, RawPETaskID: "1.nodeA", CPU: 4})
+	// a second run of the rescheduled job
+	j.Add(Entry{JobNumber: "20", PETaskID: 1, RawPETaskID: "1.nodeA", CPU: 8})
+	j.Add(Entry{JobNumber: "20", RawPETaskID: "NONE", CPU: 16})
+	// exact duplicates are ignored
+	j.Add(Entry{JobNumber: "20", PETaskID: 1, RawPETaskID: "1.nodeA", CPU: 8})
+	j.Add(Entry{JobNumber: "20", RawPETaskID: "NONE", CPU: 16})
+	task := j.Tasks[0]
+	if len(task.Subtasks) != 3 || task.Subtasks[0].CPU != 4 || task.Subtasks[1].CPU != 8 ||
+		task.Subtasks[2].RawPETaskID != "1.nodeB" {
+		t.Errorf("Wrong subtasks: %+v", task.Subtasks)
+	}
+	if task.Master == nil || task.Master.CPU != 16 || len(task.Runs) != 2 || task.Runs[0].CPU != 1 {
+		t.Errorf("Expected both runs with the last as master but got %+v", task.Runs)
+	}
+	if task.Usage.Entries != 5 || task.Usage.CPU != 31 || j.Total != task.Usage {
+		t.Errorf("The usage of all runs must be counted: %+v %+v", task.Usage, j.Total)
+	}
+}
+
+func TestJobCollectorSubmissionTime(t *testing.T) {
+	c := NewJobCollector()
+	c.Add(Entry{JobNumber: "1", SubmissionTime: time.Unix(1000, 0), CPU: 1})
+	c.Add(Entry{JobNumber: "1", SubmissionTime: time.Unix(2000, 0), CPU: 2})
+	c.Add(Entry{JobNumber: "1", SubmissionTime: time.Unix(1000, 0), TaskNumber: 2, CPU: 4})
+	jobs := c.Jobs()
+	if len(jobs) != 2 || jobs[0].Total.CPU != 5 || jobs[1].Total.CPU != 2 {
+		t.Fatalf("Expected jobs with the same number to be split by submission time: %+v", jobs)
+	}
+	if !jobs[1].SubmissionTime.Equal(time.Unix(2000, 0)) {
+		t.Errorf("Wrong submission time: %v", jobs[1].SubmissionTime)
+	}
+	if j, exists := c.Job("1"); !exists || j != jobs[0] {
+		t.Errorf("Expected the job of the last added entry")
+	}
+}
+
+func TestJobString(t *testing.T) {
+	jobs := Jobs(jobEntries)
+	out := jobs[1].String()
+	for _, expected := range []string{"jobnumber    11\n", "taskid       undefined\n",
+		"pe_tasks     2\n", "ru_wallclock 22\n", "cpu          4.500s\n", "maxvmem      300\n", "qsub_time    -/-\n"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out)
+		}
+	}
+	if blocks := strings.Count(jobs[0].String(), "jobnumber"); blocks != 2 {
+		t.Errorf("Expected a block for each array task but got %d", blocks)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// DefaultChunkSize is the size of the byte ranges which are parsed by
+// the goroutines of a ParallelReader when ChunkSize is not set.
+const DefaultChunkSize = 4 * 1024 * 1024
+
+// ParallelOptions configure a ParallelReader.
+type ParallelOptions struct {
+	// Workers is the number of parsing goroutines, runtime.NumCPU()
+	// when 0.
+	Workers int
+	// ChunkSize is the approximate size of the byte ranges. Ranges end
+	// at line boundaries.
+	ChunkSize int64
+	// Ordered delivers the entries in the order of the file. Otherwise
+	// the entries of a chunk are delivered as soon as it is parsed.
+	Ordered bool
+	// InFlight limits the number of chunks which are parsed or wait for
+	// delivery (2 * Workers when 0). When the consumer is slower than
+	// the parsers no more chunks are read.
+	InFlight int
+	// Filter selects the entries which are delivered. All entries are
+	// delivered when nil.
+	Filter func(Entry) bool
+	// MaxLineLength, Schema, and Strict are passed to the Reader of each
+	// chunk. The schema is detected for each chunk when not set.
+	MaxLineLength int
+	Schema        *Schema
+	Strict        bool
+}
+
+// ParallelReader parses an accounting file with multiple goroutines. The
+// file is split into byte ranges aligned to line boundaries, each range
+// is parsed by a Reader.
+type ParallelReader struct {
+	entries chan Entry
+	cancel  context.CancelFunc
+	closer  io.Closer
+	err     error
+}
+
+type chunk struct {
+	index      int
+	start, end int64
+}
+
+type chunkResult struct {
+	index   int
+	entries []Entry
+	err     error
+}
+
+// NewParallelReader starts parsing size bytes of r. The Entries channel
+// is closed after all entries are delivered, when an error occurs, or
+// when the context is done.
+func NewParallelReader(ctx context.Context, r io.ReaderAt, size int64, opts ParallelOptions) *ParallelReader {
+	return startParallelReader(ctx, r, size, opts, nil)
+}
+
+func startParallelReader(ctx context.Context, r io.ReaderAt, size int64, opts ParallelOptions, closer io.Closer) *ParallelReader {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &ParallelReader{entries: make(chan Entry, 1024), cancel: cancel, closer: closer}
+	go p.run(ctx, r, size, opts)
+	return p
+}
+
+// ReadFileParallel opens the accounting file and parses it with a
+// ParallelReader. The file is closed when the reader stops.
+func ReadFileParallel(ctx context.Context, file string, opts ParallelOptions) (*ParallelReader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return startParallelReader(ctx, f, fi.Size(), opts, f), nil
+}
+
+// Entries returns the channel of the parsed entries.
+func (p *ParallelReader) Entries() <-chan Entry {
+	return p.entries
+}
+
+// Err returns the error which stopped the reader. It must be called
+// after the Entries channel is closed. When the context was done before
+// all entries were delivered the context error is returned.
+func (p *ParallelReader) Err() error {
+	return p.err
+}
+
+// Stop cancels reading. The Entries channel is closed afterwards.
+func (p *ParallelReader) Stop() {
+	p.cancel()
+}
+
+func (p *ParallelReader) run(ctx context.Context, r io.ReaderAt, size int64, opts ParallelOptions) {
+	defer close(p.entries)
+	defer p.cancel()
+	if p.closer != nil {
+		defer p.closer.Close()
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	inFlight := opts.InFlight
+	if inFlight <= 0 {
+		inFlight = 2 * workers
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	jobs := make(chan chunk)
+	// results never blocks since at most inFlight chunks are dispatched
+	results := make(chan chunkResult, inFlight+1)
+	slots := make(chan struct{}, inFlight)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				results <- parseChunk(ctx, r, c, opts)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for index, start := 0, int64(0); start < size; index++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			end, err := lineBoundary(r, start+chunkSize, size)
+			if err != nil {
+				results <- chunkResult{index: index, err: err}
+				return
+			}
+			select {
+			case jobs <- chunk{index: index, start: start, end: end}:
+			case <-ctx.Done():
+				return
+			}
+			start = end
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]chunkResult)
+	next := 0
+	for res := range results {
+		if p.err != nil || ctx.Err() != nil {
+			// drain the results of the running workers
+			continue
+		}
+		if !opts.Ordered {
+			p.deliver(ctx, res, slots)
+			continue
+		}
+		pending[res.index] = res
+		for {
+			res, exists := pending[next]
+			if !exists || p.err != nil {
+				break
+			}
+			delete(pending, next)
+			p.deliver(ctx, res, slots)
+			next++
+		}
+	}
+	if p.err == nil && ctx.Err() != nil {
+		p.err = ctx.Err()
+	}
+}
+
+// deliver sends the entries of a chunk and releases its slot.
+func (p *ParallelReader) deliver(ctx context.Context, res chunkResult, slots chan struct{}) {
+	if res.err != nil {
+		p.err = res.err
+		p.cancel()
+		return
+	}
+	for _, e := range res.entries {
+		select {
+		case p.entries <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+	<-slots
+}
+
+// parseChunk parses the lines of a byte range.
+func parseChunk(ctx context.Context, r io.ReaderAt, c chunk, opts ParallelOptions) chunkResult {
+	res := chunkResult{index: c.index}
+	rd := NewReader(io.NewSectionReader(r, c.start, c.end-c.start))
+	rd.MaxLineLength = opts.MaxLineLength
+	rd.Schema = opts.Schema
+	rd.Strict = opts.Strict
+	for rd.Next() {
+		if len(res.entries)%1024 == 0 && ctx.Err() != nil {
+			return res
+		}
+		if opts.Filter == nil || opts.Filter(rd.Entry()) {
+			res.entries = append(res.entries, rd.Entry())
+		}
+	}
+	if err := rd.Err(); err != nil {
+		res.err = fmt.Errorf("chunk at byte offset %d: %s", c.start, err)
+	}
+	return res
+}
+
+// lineBoundary returns the offset after the first newline at or after
+// pos-1, i.e. the start of the first line which begins at or after pos.
+func lineBoundary(r io.ReaderAt, pos, size int64) (int64, error) {
+	if pos >= size {
+		return size, nil
+	}
+	buf := make([]byte, 64*1024)
+	for offset := pos - 1; offset < size; offset += int64(len(buf)) {
+		n, err := r.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return size, nil
+}
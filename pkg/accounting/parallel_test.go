@@ -0,0 +1,111 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testAccountingFile(jobs int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Version: 8.4.0\n# \n")
+	for i := 1; i <= jobs; i++ {
+		buf.WriteString(jobLine(strconv.Itoa(i)))
+	}
+	return buf.Bytes()
+}
+
+func TestParallelReaderOrdered(t *testing.T) {
+	data := testAccountingFile(1000)
+	p := NewParallelReader(context.Background(), bytes.NewReader(data), int64(len(data)),
+		ParallelOptions{Workers: 4, ChunkSize: 1000, Ordered: true, InFlight: 3})
+	next := 1
+	for e := range p.Entries() {
+		if e.JobNumber != strconv.Itoa(next) {
+			t.Fatalf("Expected job %d but got %s", next, e.JobNumber)
+		}
+		next++
+	}
+	if p.Err() != nil || next != 1001 {
+		t.Errorf("Expected 1000 entries but got %d (%v)", next-1, p.Err())
+	}
+}
+
+func TestParallelReaderUnorderedFilter(t *testing.T) {
+	data := testAccountingFile(1000)
+	p := NewParallelReader(context.Background(), bytes.NewReader(data), int64(len(data)),
+		ParallelOptions{Workers: 3, ChunkSize: 4096, Strict: true,
+			Filter: func(e Entry) bool { return strings.HasSuffix(e.JobNumber, "7") }})
+	var jobs []int
+	for e := range p.Entries() {
+		job, _ := strconv.Atoi(e.JobNumber)
+		jobs = append(jobs, job)
+	}
+	if p.Err() != nil {
+		t.Fatal(p.Err())
+	}
+	sort.Ints(jobs)
+	if len(jobs) != 100 || jobs[0] != 7 || jobs[99] != 997 {
+		t.Errorf("Wrong filtered jobs: %v", jobs)
+	}
+}
+
+func TestParallelReaderError(t *testing.T) {
+	data := testAccountingFile(1000)
+	data = bytes.Replace(data, []byte(":500:sge:0:"), []byte(":500:sge:x:"), 1)
+	p := NewParallelReader(context.Background(), bytes.NewReader(data), int64(len(data)),
+		ParallelOptions{Workers: 4, ChunkSize: 2000, Ordered: true, Strict: true})
+	count := 0
+	for range p.Entries() {
+		count++
+	}
+	if p.Err() == nil || !strings.Contains(p.Err().Error(), "priority") {
+		t.Errorf("Expected error for priority column but got %v", p.Err())
+	}
+	if count >= 500 {
+		t.Errorf("Entries after the error must not be delivered: %d", count)
+	}
+}
+
+func TestParallelReaderCancel(t *testing.T) {
+	data := testAccountingFile(1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewParallelReader(ctx, bytes.NewReader(data), int64(len(data)),
+		ParallelOptions{Workers: 2, ChunkSize: 1000, Ordered: true})
+	<-p.Entries()
+	cancel()
+	for range p.Entries() {
+	}
+	if p.Err() != context.Canceled {
+		t.Errorf("Expected canceled error but got %v", p.Err())
+	}
+}
+
+func TestLineBoundary(t *testing.T) {
+	data := []byte("aaa\nbbb\nccc")
+	r := bytes.NewReader(data)
+	for pos, expected := range map[int64]int64{1: 4, 4: 4, 5: 8, 9: 11, 20: 11} {
+		if got, _ := lineBoundary(r, pos, int64(len(data))); got != expected {
+			t.Errorf("Boundary for %d: expected %d but got %d", pos, expected, got)
+		}
+	}
+}
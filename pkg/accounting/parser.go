@@ -0,0 +1,288 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// maxInterned limits the number of strings which are shared between
+// the entries parsed by a Parser.
+const maxInterned = 4096
+
+// pow10 are the powers of ten which are exactly representable as
+// float64.
+var pow10 = [...]float64{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10,
+	1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22}
+
+// Parser parses accounting lines without reflection and without
+// splitting the line. Columns with few distinct values (like queue,
+// host, owner, or project names) share their strings between entries.
+// The results are identical to ParseLine. A Parser must not be used
+// concurrently.
+type Parser struct {
+	scratch  []byte
+	interned map[string]string
+}
+
+// NewParser returns a Parser. The zero Parser is usable as well but
+// does not share strings.
+func NewParser() *Parser {
+	return &Parser{interned: make(map[string]string)}
+}
+
+// Parse parses the line into the entry. All fields are overwritten.
+// Values which can't be converted are left zero like ParseLine does,
+// hence the error is always nil.
+func (p *Parser) Parse(line []byte, e *Entry) error {
+	*e = Entry{}
+	for i := 0; i < len(columnNames); i++ {
+		col := line
+		end := bytes.IndexByte(line, ':')
+		if end >= 0 {
+			col, line = line[:end], line[end+1:]
+		}
+		if bytes.IndexByte(col, 0xFF) >= 0 {
+			p.scratch = append(p.scratch[:0], col...)
+			for j := range p.scratch {
+				if p.scratch[j] == 0xFF {
+					p.scratch[j] = ':'
+				}
+			}
+			col = p.scratch
+		}
+		switch i {
+		case 0:
+			e.Qname = p.intern(col)
+		case 1:
+			e.Hostname = p.intern(col)
+		case 2:
+			e.GroupID = p.intern(col)
+		case 3:
+			e.Owner = p.intern(col)
+		case 4:
+			e.JobName = string(col)
+		case 5:
+			e.JobNumber = string(col)
+		case 6:
+			e.Account = p.intern(col)
+		case 7:
+			e.PosixPriority = int(fastInt(col))
+		case 8:
+			e.SubmissionTime = fastTime(col)
+		case 9:
+			e.StartTime = fastTime(col)
+		case 10:
+			e.EndTime = fastTime(col)
+		case 11:
+			e.Failed = int(fastInt(col))
+		case 12:
+			e.ExitStatus = int(fastInt(col))
+		case 13:
+			e.RuWallclock = fastFloat(col)
+		case 14:
+			e.RuUtime = fastFloat(col)
+		case 15:
+			e.RuStime = fastFloat(col)
+		case 16:
+			e.RuMaxRSS = fastInt(col)
+		case 17:
+			e.RuIxRSS = fastInt(col)
+		case 18:
+			e.RuIsmRSS = fastInt(col)
+		case 19:
+			e.RuIdRSS = fastInt(col)
+		case 20:
+			e.RuIsRSS = fastInt(col)
+		case 21:
+			e.RuMinFlt = fastInt(col)
+		case 22:
+			e.RuMajFlt = fastInt(col)
+		case 23:
+			e.RunSwap = fastInt(col)
+		case 24:
+			e.RuInblock = fastInt(col)
+		case 25:
+			e.RuOublock = fastInt(col)
+		case 26:
+			e.RuMsgSnd = fastInt(col)
+		case 27:
+			e.RuMsgRcv = fastInt(col)
+		case 28:
+			e.RunSignals = fastInt(col)
+		case 29:
+			e.RuNvCsw = fastInt(col)
+		case 30:
+			e.RuNivCsw = fastInt(col)
+		case 31:
+			e.Project = p.intern(col)
+		case 32:
+			e.Department = p.intern(col)
+		case 33:
+			e.GrantedPE = p.intern(col)
+		case 34:
+			e.Slots = int(fastInt(col))
+		case 35:
+			e.TaskNumber = int(fastInt(col))
+		case 36:
+			e.CPU = fastFloat(col)
+		case 37:
+			e.MEM = fastFloat(col)
+		case 38:
+			e.IO = fastFloat(col)
+		case 39:
+			e.Category = p.intern(col)
+		case 40:
+			e.IOW = fastFloat(col)
+		case 41:
+			e.PETaskID = int(fastInt(col))
+			e.RawPETaskID = p.intern(col)
+		case 42:
+			e.MaxVmem = fastInt(col)
+		case 43:
+			e.AdvanceReservationID = int(fastInt(col))
+		case 44:
+			e.AdvanceReservationSubmissionTime = fastTime(col)
+		case 45:
+			e.JobClass = p.intern(col)
+		case 46:
+			e.QdelInfo = p.intern(col)
+		case 47:
+			e.MaxRSS = fastInt(col)
+		case 48:
+			e.MaxPSS = fastInt(col)
+		case 49:
+			e.SubmitHost = p.intern(col)
+		case 50:
+			e.CurrentWorkingDirectory = p.intern(col)
+		case 51:
+			e.SubmitCommand = string(col)
+		case 52:
+			e.WallClock = fastFloat(col)
+		}
+		if end < 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// intern returns a shared string for the value.
+func (p *Parser) intern(b []byte) string {
+	if s, exists := p.interned[string(b)]; exists {
+		return s
+	}
+	s := string(b)
+	if p.interned != nil && len(p.interned) < maxInterned {
+		p.interned[s] = s
+	}
+	return s
+}
+
+// fastInt converts plain decimal numbers directly, all others like
+// parseInt does.
+func fastInt(b []byte) int64 {
+	digits := b
+	negative := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		i, _ := parseInt(b)
+		return i
+	}
+	var n int64
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			i, _ := parseInt(b)
+			return i
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if negative {
+		return -n
+	}
+	return n
+}
+
+// fastFloat converts decimal numbers with up to 15 significant digits
+// exactly (the mantissa and the power of ten are exact float64 values,
+// hence the division is correctly rounded like strconv.ParseFloat). All
+// other values are converted by strconv.ParseFloat.
+func fastFloat(b []byte) float64 {
+	digits := b
+	negative := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	var mantissa int64
+	n, fraction, dot := 0, 0, false
+	for _, c := range digits {
+		switch {
+		case c >= '0' && c <= '9':
+			mantissa = mantissa*10 + int64(c-'0')
+			n++
+			if dot {
+				fraction++
+			}
+		case c == '.' && !dot:
+			dot = true
+		default:
+			n = 16
+		}
+		if n > 15 {
+			f, _ := strconv.ParseFloat(string(b), 64)
+			return f
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	f := float64(mantissa) / pow10[fraction]
+	if negative {
+		return -f
+	}
+	return f
+}
+
+// fastTime converts a time stamp like parseTime without a schema.
+func fastTime(b []byte) time.Time {
+	digits := b
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	valid := len(digits) > 0 && len(digits) <= 18
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			valid = false
+			break
+		}
+	}
+	if !valid {
+		t, _ := parseTime(b, false)
+		return t
+	}
+	timeInt := fastInt(b)
+	if timeInt > 631152000000 {
+		return msEpochTime(timeInt)
+	}
+	return sEpochTime(timeInt)
+}
@@ -0,0 +1,74 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParserIdenticalResults(t *testing.T) {
+	columns := strings.Split(testline1, ":")
+	lines := []string{
+		testline1,
+		"",
+		":::",
+		strings.Join(columns[:45], ":"),
+		strings.Join(columns[:10], ":"),
+		testline1 + ":extra:columns",
+		strings.Replace(testline1, "vcontrol.default", "a\xFFb\xFFc", -1),
+	}
+	// replace each column by values which are special for the parsers
+	for i := range columns {
+		for _, value := range []string{"", "NONE", "-12", "+7", "1.5", "-0.25", "1e3", ".5", "5.",
+			"1.2.3", "abc", "1.node1", "99999999999999999999", "0.12345678901234567",
+			"1448438703533", "1448438703", "-", "\xFF"} {
+			c := append([]string{}, columns...)
+			c[i] = value
+			lines = append(lines, strings.Join(c, ":"))
+		}
+	}
+	p := NewParser()
+	for _, line := range lines {
+		var fast Entry
+		if err := p.Parse([]byte(line), &fast); err != nil {
+			t.Fatal(err)
+		}
+		reflective, _ := parseColumns(splitLine([]byte(line)), false, false)
+		if !reflect.DeepEqual(fast, reflective) {
+			t.Errorf("Different results for line %q:\n%v\n%v", line, fast, reflective)
+		}
+	}
+}
+
+func BenchmarkParseLineReflect(b *testing.B) {
+	line := []byte(testline1)
+	for i := 0; i < b.N; i++ {
+		parseColumns(splitLine(line), false, false)
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	line := []byte(testline1)
+	p := NewParser()
+	var e Entry
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Parse(line, &e)
+	}
+}
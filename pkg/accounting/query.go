@@ -0,0 +1,588 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"fmt"
+	"github.com/dgruber/ugego/pkg/quantity"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Predicate selects accounting entries. It can be used as Filter of
+// ParallelOptions and SummaryOptions.
+type Predicate func(Entry) bool
+
+// And returns a predicate which is true when all predicates are true.
+func And(predicates ...Predicate) Predicate {
+	return func(e Entry) bool {
+		for _, p := range predicates {
+			if !p(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a predicate which is true when one of the predicates is
+// true.
+func Or(predicates ...Predicate) Predicate {
+	return func(e Entry) bool {
+		for _, p := range predicates {
+			if p(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns the negation of the predicate.
+func Not(p Predicate) Predicate {
+	return func(e Entry) bool {
+		return !p(e)
+	}
+}
+
+// Requested returns a predicate which is true when the job requested
+// the resource (qsub -l) in its submit command or category.
+func Requested(resource string) Predicate {
+	return func(e Entry) bool {
+		_, exists := requestedComplexes(e)[resource]
+		return exists
+	}
+}
+
+// queryField accesses an Entry field. Exactly one function is set.
+type queryField struct {
+	str  func(e *Entry) string
+	num  func(e *Entry) float64
+	time func(e *Entry) time.Time
+}
+
+func stringField(f func(e *Entry) string) queryField  { return queryField{str: f} }
+func floatField(f func(e *Entry) float64) queryField  { return queryField{num: f} }
+func timeField(f func(e *Entry) time.Time) queryField { return queryField{time: f} }
+func intField(f func(e *Entry) int) queryField {
+	return floatField(func(e *Entry) float64 { return float64(f(e)) })
+}
+func int64Field(f func(e *Entry) int64) queryField {
+	return floatField(func(e *Entry) float64 { return float64(f(e)) })
+}
+
+// queryFields are the fields which can be used in queries by their
+// accounting(5) column names.
+var queryFields = map[string]queryField{
+	"qname":              stringField(func(e *Entry) string { return e.Qname }),
+	"hostname":           stringField(func(e *Entry) string { return e.Hostname }),
+	"group":              stringField(func(e *Entry) string { return e.GroupID }),
+	"owner":              stringField(func(e *Entry) string { return e.Owner }),
+	"job_name":           stringField(func(e *Entry) string { return e.JobName }),
+	"job_number":         int64Field(func(e *Entry) int64 { n, _ := strconv.ParseInt(e.JobNumber, 10, 64); return n }),
+	"account":            stringField(func(e *Entry) string { return e.Account }),
+	"priority":           intField(func(e *Entry) int { return e.PosixPriority }),
+	"submission_time":    timeField(func(e *Entry) time.Time { return e.SubmissionTime }),
+	"start_time":         timeField(func(e *Entry) time.Time { return e.StartTime }),
+	"end_time":           timeField(func(e *Entry) time.Time { return e.EndTime }),
+	"failed":             intField(func(e *Entry) int { return e.Failed }),
+	"exit_status":        intField(func(e *Entry) int { return e.ExitStatus }),
+	"ru_wallclock":       floatField(func(e *Entry) float64 { return e.RuWallclock }),
+	"ru_utime":           floatField(func(e *Entry) float64 { return e.RuUtime }),
+	"ru_stime":           floatField(func(e *Entry) float64 { return e.RuStime }),
+	"ru_maxrss":          int64Field(func(e *Entry) int64 { return e.RuMaxRSS }),
+	"ru_minflt":          int64Field(func(e *Entry) int64 { return e.RuMinFlt }),
+	"ru_majflt":          int64Field(func(e *Entry) int64 { return e.RuMajFlt }),
+	"ru_inblock":         int64Field(func(e *Entry) int64 { return e.RuInblock }),
+	"ru_oublock":         int64Field(func(e *Entry) int64 { return e.RuOublock }),
+	"ru_nvcsw":           int64Field(func(e *Entry) int64 { return e.RuNvCsw }),
+	"ru_nivcsw":          int64Field(func(e *Entry) int64 { return e.RuNivCsw }),
+	"project":            stringField(func(e *Entry) string { return e.Project }),
+	"department":         stringField(func(e *Entry) string { return e.Department }),
+	"granted_pe":         stringField(func(e *Entry) string { return e.GrantedPE }),
+	"slots":              intField(func(e *Entry) int { return e.Slots }),
+	"task_number":        intField(func(e *Entry) int { return e.TaskNumber }),
+	"cpu":                floatField(func(e *Entry) float64 { return e.CPU }),
+	"mem":                floatField(func(e *Entry) float64 { return e.MEM }),
+	"io":                 floatField(func(e *Entry) float64 { return e.IO }),
+	"category":           stringField(func(e *Entry) string { return e.Category }),
+	"iow":                floatField(func(e *Entry) float64 { return e.IOW }),
+	"pe_taskid":          intField(func(e *Entry) int { return e.PETaskID }),
+	"maxvmem":            int64Field(func(e *Entry) int64 { return e.MaxVmem }),
+	"arid":               intField(func(e *Entry) int { return e.AdvanceReservationID }),
+	"ar_submission_time": timeField(func(e *Entry) time.Time { return e.AdvanceReservationSubmissionTime }),
+	"job_class":          stringField(func(e *Entry) string { return e.JobClass }),
+	"qdel_info":          stringField(func(e *Entry) string { return e.QdelInfo }),
+	"maxrss":             int64Field(func(e *Entry) int64 { return e.MaxRSS }),
+	"maxpss":             int64Field(func(e *Entry) int64 { return e.MaxPSS }),
+	"submit_host":        stringField(func(e *Entry) string { return e.SubmitHost }),
+	"cwd":                stringField(func(e *Entry) string { return e.CurrentWorkingDirectory }),
+	"submit_cmd":         stringField(func(e *Entry) string { return e.SubmitCommand }),
+	"wallclock":          floatField(func(e *Entry) float64 { return e.WallClock }),
+}
+
+// queryAliases are short names of query fields.
+var queryAliases = map[string]string{
+	"queue":  "qname",
+	"host":   "hostname",
+	"user":   "owner",
+	"name":   "job_name",
+	"job":    "job_number",
+	"task":   "task_number",
+	"pe":     "granted_pe",
+	"submit": "submission_time",
+	"start":  "start_time",
+	"end":    "end_time",
+	"exit":   "exit_status",
+	"class":  "job_class",
+}
+
+// queryTimeLayouts are the accepted formats of time values. Values
+// without time zone are local times.
+var queryTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseQueryTime parses a time value in one of the queryTimeLayouts or
+// as seconds since epoch.
+func parseQueryTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	for _, layout := range queryTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s is not a time (like 2006-01-02 or 2006-01-02T15:04:05)", value)
+}
+
+// requestedComplexes returns the resource requests of the job from the
+// category and the submit command.
+func requestedComplexes(e Entry) map[string]string {
+	complexes := ParseSubmitCommand(e.Category).Complexes
+	for name, value := range ParseSubmitCommand(e.SubmitCommand).Complexes {
+		complexes[name] = value
+	}
+	return complexes
+}
+
+// orderOp returns a function which checks the result of a three way
+// comparison for the ordering operator.
+func orderOp(op string) (func(c int) bool, error) {
+	switch op {
+	case "=", "==":
+		return func(c int) bool { return c == 0 }, nil
+	case "!=":
+		return func(c int) bool { return c != 0 }, nil
+	case "<":
+		return func(c int) bool { return c < 0 }, nil
+	case "<=":
+		return func(c int) bool { return c <= 0 }, nil
+	case ">":
+		return func(c int) bool { return c > 0 }, nil
+	case ">=":
+		return func(c int) bool { return c >= 0 }, nil
+	}
+	return nil, fmt.Errorf("operator %s can't be used for numbers and times", op)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// stringMatcher returns a function which compares strings with the
+// value. = and != match wildcard patterns (like gpu*) when the value
+// contains wildcards, ~ and !~ match regular expressions.
+func stringMatcher(op, value string) (func(s string) bool, error) {
+	switch op {
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == "!~" {
+			return func(s string) bool { return !re.MatchString(s) }, nil
+		}
+		return re.MatchString, nil
+	case "=", "==", "!=":
+		match := func(s string) bool { return s == value }
+		if strings.ContainsAny(value, "*?[") {
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s", value)
+			}
+			match = func(s string) bool {
+				matched, _ := path.Match(value, s)
+				return matched
+			}
+		}
+		if op == "!=" {
+			return func(s string) bool { return !match(s) }, nil
+		}
+		return match, nil
+	}
+	matches, err := orderOp(op)
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) bool { return matches(strings.Compare(s, value)) }, nil
+}
+
+// Compare returns a predicate which compares an entry field with the
+// value. The field is an accounting(5) column name (like owner, failed,
+// or end_time) or one of the aliases queue, host, user, name, job,
+// task, pe, submit, start, end, exit, and class. Resource requests
+// are compared with l:<name>, requested environment variables with
+// v:<name>. The operators are =, !=, <, <=, >, >= and for strings the
+// regular expression matches ~ and !~.
+//
+// Numbers can have Grid Engine memory units (like 4G), times are dates
+// (like 2026-01-01), local times (like 2026-01-01T15:04:05), RFC 3339
+// times, or seconds since epoch. Resource requests are compared as
+// numbers when both values are numeric. When the job did not request
+// the resource only != and !~ are true.
+func Compare(field, op, value string) (Predicate, error) {
+	if strings.HasPrefix(field, "l:") || strings.HasPrefix(field, "v:") {
+		return compareRequest(field, op, value)
+	}
+	name := field
+	if alias, exists := queryAliases[name]; exists {
+		name = alias
+	}
+	f, exists := queryFields[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown field %s", field)
+	}
+	switch {
+	case f.str != nil:
+		match, err := stringMatcher(op, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field, err)
+		}
+		return func(e Entry) bool { return match(f.str(&e)) }, nil
+	case f.num != nil:
+		matches, err := orderOp(op)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field, err)
+		}
+		n, err := quantity.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field, err)
+		}
+		return func(e Entry) bool { return matches(compareFloats(f.num(&e), n)) }, nil
+	}
+	matches, err := orderOp(op)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", field, err)
+	}
+	t, err := parseQueryTime(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", field, err)
+	}
+	return func(e Entry) bool {
+		ft := f.time(&e)
+		switch {
+		case ft.Before(t):
+			return matches(-1)
+		case ft.After(t):
+			return matches(1)
+		}
+		return matches(0)
+	}, nil
+}
+
+// compareRequest compares a resource request (l:<name>) or an
+// environment variable (v:<name>) of the submit command.
+func compareRequest(field, op, value string) (Predicate, error) {
+	name := field[2:]
+	requests := requestedComplexes
+	if field[0] == 'v' {
+		requests = func(e Entry) map[string]string {
+			return ParseSubmitCommand(e.SubmitCommand).Environment
+		}
+	}
+	missing := op == "!=" || op == "!~"
+	n, numErr := quantity.Parse(value)
+	var match func(s string) bool
+	switch op {
+	case "~", "!~", "=", "==", "!=":
+		matchString, err := stringMatcher(op, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field, err)
+		}
+		match = matchString
+		if numErr == nil && op != "~" && op != "!~" {
+			match = func(s string) bool {
+				if got, err := quantity.Parse(s); err == nil {
+					return (got == n) == (op != "!=")
+				}
+				return matchString(s)
+			}
+		}
+	default:
+		matches, err := orderOp(op)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field, err)
+		}
+		if numErr != nil {
+			return nil, fmt.Errorf("%s: %s", field, numErr)
+		}
+		match = func(s string) bool {
+			got, err := quantity.Parse(s)
+			return err == nil && matches(compareFloats(got, n))
+		}
+	}
+	return func(e Entry) bool {
+		got, exists := requests(e)[name]
+		if !exists {
+			return missing
+		}
+		return match(got)
+	}, nil
+}
+
+// ParseQuery parses a query expression into a predicate like
+//
+//	owner=alice and failed!=0 and end>=2026-01-01 and l:h_vmem>4G
+//
+// A query consists of comparisons (see Compare) combined with and, or,
+// not (or &&, ||, !) and parentheses. and binds stronger than or. A
+// resource name without comparison (like l:gpu) is true when the job
+// requested the resource. Values which contain spaces or operator
+// characters must be quoted ("..." with Go escapes or '...'). The
+// empty query selects all entries.
+func ParseQuery(query string) (Predicate, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if p.peek().kind == tokenEnd {
+		return func(Entry) bool { return true }, nil
+	}
+	predicate, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, fmt.Errorf("position %d: unexpected %s", t.pos, t.text)
+	}
+	return predicate, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenOpen
+	tokenClose
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// queryOps are the operators, two character operators first.
+var queryOps = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "=", "<", ">", "~", "!"}
+
+func lexQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: tokenOpen, text: "(", pos: i})
+			i++
+			continue
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: tokenClose, text: ")", pos: i})
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(query) && query[end] != c {
+				if query[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(query) {
+				return nil, fmt.Errorf("position %d: unterminated string", i)
+			}
+			text := query[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(query[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("position %d: invalid string: %s", i, err)
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, queryToken{kind: tokenString, text: text, pos: i})
+			i = end + 1
+			continue
+		}
+		op := ""
+		for _, o := range queryOps {
+			if strings.HasPrefix(query[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op != "" {
+			tokens = append(tokens, queryToken{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+			continue
+		}
+		end := i
+		for end < len(query) && !strings.ContainsRune(" \t\n()\"'=!<>~&|", rune(query[end])) {
+			end++
+		}
+		tokens = append(tokens, queryToken{kind: tokenWord, text: query[i:end], pos: i})
+		i = end
+	}
+	return append(tokens, queryToken{kind: tokenEnd, text: "end of query", pos: len(query)}), nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	next   int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) consume() queryToken {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+// keyword checks whether the next token is the keyword or operator.
+func (p *queryParser) keyword(word, op string) bool {
+	t := p.peek()
+	return (t.kind == tokenWord && strings.EqualFold(t.text, word)) || (t.kind == tokenOp && t.text == op)
+}
+
+func (p *queryParser) or() (Predicate, error) {
+	predicates, err := p.list(p.and, "or", "||")
+	if err != nil {
+		return nil, err
+	}
+	if len(predicates) == 1 {
+		return predicates[0], nil
+	}
+	return Or(predicates...), nil
+}
+
+func (p *queryParser) and() (Predicate, error) {
+	predicates, err := p.list(p.not, "and", "&&")
+	if err != nil {
+		return nil, err
+	}
+	if len(predicates) == 1 {
+		return predicates[0], nil
+	}
+	return And(predicates...), nil
+}
+
+// list parses operands separated by the keyword.
+func (p *queryParser) list(operand func() (Predicate, error), word, op string) ([]Predicate, error) {
+	var predicates []Predicate
+	for {
+		predicate, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+		if !p.keyword(word, op) {
+			return predicates, nil
+		}
+		p.consume()
+	}
+}
+
+func (p *queryParser) not() (Predicate, error) {
+	if p.keyword("not", "!") {
+		p.consume()
+		predicate, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not(predicate), nil
+	}
+	if p.peek().kind == tokenOpen {
+		p.consume()
+		predicate, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.consume(); t.kind != tokenClose {
+			return nil, fmt.Errorf("position %d: expected ) but got %s", t.pos, t.text)
+		}
+		return predicate, nil
+	}
+	return p.comparison()
+}
+
+func (p *queryParser) comparison() (Predicate, error) {
+	field := p.consume()
+	if field.kind != tokenWord {
+		return nil, fmt.Errorf("position %d: expected field but got %s", field.pos, field.text)
+	}
+	op := p.peek()
+	if op.kind != tokenOp || op.text == "!" || op.text == "&&" || op.text == "||" {
+		if strings.HasPrefix(field.text, "l:") {
+			return Requested(field.text[2:]), nil
+		}
+		return nil, fmt.Errorf("position %d: expected operator after %s", op.pos, field.text)
+	}
+	p.consume()
+	value := p.consume()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("position %d: expected value but got %s", value.pos, value.text)
+	}
+	predicate, err := Compare(field.text, op.text, value.text)
+	if err != nil {
+		return nil, fmt.Errorf("position %d: %s", field.pos, err)
+	}
+	return predicate, nil
+}
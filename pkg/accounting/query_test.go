@@ -0,0 +1,127 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var queryEntries = []Entry{
+	{Owner: "alice", JobNumber: "1", Qname: "gpu.q", Failed: 100,
+		EndTime:       time.Date(2026, 2, 1, 12, 0, 0, 0, time.Local),
+		SubmitCommand: "qsub -l h_vmem=8G,gpu=2 -v MODE=fast job.sh"},
+	{Owner: "alice", JobNumber: "2", Qname: "all.q", CPU: 12.5,
+		EndTime:  time.Date(2025, 12, 31, 12, 0, 0, 0, time.Local),
+		Category: "-U staff -l h_vmem=2G -q all.q"},
+	{Owner: "bob", JobNumber: "3", Qname: "gpu.q", Failed: 1, MaxVmem: 5 << 30,
+		EndTime: time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)},
+}
+
+func selectJobs(t *testing.T, query string) string {
+	p, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %s", query, err)
+	}
+	var jobs []string
+	for _, e := range queryEntries {
+		if p(e) {
+			jobs = append(jobs, e.JobNumber)
+		}
+	}
+	return strings.Join(jobs, ",")
+}
+
+func TestParseQuery(t *testing.T) {
+	for query, expected := range map[string]string{
+		"":                          "1,2,3",
+		"owner=alice":               "1,2",
+		"owner=alice and failed!=0": "1",
+		"owner=alice and failed!=0 and end>=2026-01-01 and l:h_vmem>4G": "1",
+		"end>=2026-01-01":                       "1,3",
+		"end<'2026-01-01 00:00'":                "2",
+		"queue=gpu* && !(owner==bob)":           "1",
+		"not owner=alice or cpu>12":             "2,3",
+		"owner=bob or owner=alice and cpu>12":   "2,3",
+		"(owner=bob or owner=alice) and cpu>12": "2",
+		"maxvmem>=5G":                           "3",
+		"job>1 and job<=3":                      "2,3",
+		"l:gpu":                                 "1",
+		"l:h_vmem=2G":                           "2",
+		"l:h_vmem=2048M":                        "2",
+		"l:h_vmem!=2G":                          "1,3",
+		"l:gpu>=2":                              "1",
+		"v:MODE=fast":                           "1",
+		`category~"-U staff"`:                   "2",
+		"owner~^a AND qname!~all":               "1",
+	} {
+		if got := selectJobs(t, query); got != expected {
+			t.Errorf("Query %q: expected jobs %s but got %s", query, expected, got)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for query, expected := range map[string]string{
+		"owner":           "expected operator",
+		"owner=":          "expected value",
+		"unknown=1":       "unknown field",
+		"failed=abc":      "not numeric",
+		"end>yesterday":   "not a time",
+		"failed~1":        "can't be used",
+		"owner=alice)":    "unexpected )",
+		"(owner=alice":    "expected )",
+		`owner="alice`:    "unterminated string",
+		"owner=alice and": "expected field",
+		"l:h_vmem>lots":   "not numeric",
+		`owner~"("`:       "error parsing regexp",
+	} {
+		_, err := ParseQuery(query)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Query %q: expected error with %q but got %v", query, expected, err)
+		}
+	}
+}
+
+func TestPredicateBuilder(t *testing.T) {
+	alice, err := Compare("owner", "=", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := Compare("failed", ">", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Or(And(alice, failed), Not(Requested("h_vmem")))
+	var jobs []string
+	for _, e := range queryEntries {
+		if p(e) {
+			jobs = append(jobs, e.JobNumber)
+		}
+	}
+	if strings.Join(jobs, ",") != "1,3" {
+		t.Errorf("Expected jobs 1,3 but got %v", jobs)
+	}
+	s := NewSummarizer(SummaryOptions{Filter: p})
+	for _, e := range queryEntries {
+		s.Add(e)
+	}
+	if s.Summary().Total.Entries != 2 {
+		t.Errorf("Predicate does not work as summary filter")
+	}
+}
@@ -0,0 +1,209 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLineLength is the maximum length of an accounting line
+// which is accepted by a Reader when MaxLineLength is not set. The
+// submit command column can make lines very long.
+const DefaultMaxLineLength = 1024 * 1024
+
+// ParseError is returned by a Reader when a line can't be read or
+// parsed. Line is the line number within the input starting with 1.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Reader reads the entries of an accounting file. Comment lines (like
+// the "# Version" header) and blank lines are skipped.
+//
+//	r := accounting.NewReader(file)
+//	for r.Next() {
+//	    e := r.Entry()
+//	    ...
+//	}
+//	if err := r.Err(); err != nil {
+//	    ...
+//	}
+type Reader struct {
+	// MaxLineLength is the maximum length of a line in bytes. Longer
+	// lines stop the Reader with an error. When 0 DefaultMaxLineLength
+	// is used.
+	MaxLineLength int
+	// Schema of the accounting file. When nil it is detected from the
+	// "# Version:" header and the number of columns of the first entry.
+	// When they disagree (like for Son of Grid Engine files which have
+	// 8.1 versions but 45 columns) the number of columns wins.
+	Schema *Schema
+	// Strict stops the Reader with an error when the schema can't be
+	// detected, a line does not have the columns of the schema, or a
+	// value can't be converted.
+	Strict bool
+
+	r      *bufio.Reader
+	header *Schema
+	parser *Parser
+	line   int
+	text   []byte
+	entry  Entry
+	err    error
+}
+
+// NewReader returns a Reader which reads accounting entries from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), parser: NewParser()}
+}
+
+// Next reads the next entry. It returns false when there are no more
+// entries or an error occurred (see Err).
+func (r *Reader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			if err != io.EOF {
+				r.err = &ParseError{Line: r.line, Err: err}
+			} else {
+				r.err = err
+			}
+			return false
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if line[0] == '#' {
+			if version, isHeader := headerVersion(line); isHeader && r.Schema == nil {
+				s, err := SchemaForVersion(version)
+				if err != nil && r.Strict {
+					r.err = &ParseError{Line: r.line, Err: err}
+					return false
+				}
+				r.header = s
+			}
+			continue
+		}
+		if r.Schema == nil {
+			s, err := detectSchema(r.header, bytes.Count(line, []byte(":"))+1)
+			if err != nil && r.Strict {
+				r.err = &ParseError{Line: r.line, Err: err}
+				return false
+			}
+			r.Schema = s
+		}
+		if r.Strict {
+			r.entry, err = r.Schema.ParseLine(line, true)
+		} else {
+			err = r.parser.Parse(line, &r.entry)
+		}
+		if err != nil {
+			r.err = &ParseError{Line: r.line, Err: err}
+			return false
+		}
+		r.text = line
+		return true
+	}
+}
+
+// detectSchema returns the schema of the header when it has the number
+// of columns of the first entry, otherwise the schema for the number of
+// columns. 45 columns in a file with a Grid Engine 8 header are written
+// by Son of Grid Engine.
+func detectSchema(header *Schema, columns int) (*Schema, error) {
+	if header != nil && header.Columns == columns {
+		return header, nil
+	}
+	s, err := SchemaForColumns(columns)
+	if err != nil {
+		if header != nil {
+			return header, nil
+		}
+		return nil, err
+	}
+	if s == SGE62 && header != nil && header != SGE62 {
+		return SoGE, nil
+	}
+	return s, nil
+}
+
+// Entry returns the entry which was read by the last call of Next.
+func (r *Reader) Entry() Entry {
+	return r.entry
+}
+
+// Text returns the line of the entry which was read by the last call
+// of Next without line ending. It is valid until the next call of Next.
+func (r *Reader) Text() []byte {
+	return r.text
+}
+
+// Line returns the number of the line which was read last.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Err returns the error which stopped the Reader, or nil when the end
+// of the input was reached.
+func (r *Reader) Err() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+// readLine returns the next line without line ending. The last line
+// does not need to end with a newline.
+func (r *Reader) readLine() ([]byte, error) {
+	max := r.MaxLineLength
+	if max <= 0 {
+		max = DefaultMaxLineLength
+	}
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		if len(line)+len(bytes.TrimRight(chunk, "\r\n")) > max {
+			r.line++
+			return nil, fmt.Errorf("line exceeds maximum length of %d bytes", max)
+		}
+		line = append(line, chunk...)
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+		case nil:
+		default:
+			return nil, err
+		}
+		r.line++
+		return bytes.TrimRight(line, "\r\n"), nil
+	}
+}
@@ -0,0 +1,101 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	input := "# Version: 8.3.1\n# \n\n" + testline1 + "\r\n\n" +
+		strings.Replace(testline1, ":1:sge:", ":2:sge:", 1)
+	r := NewReader(strings.NewReader(input))
+	var jobs []string
+	var lines []int
+	for r.Next() {
+		jobs = append(jobs, r.Entry().JobNumber)
+		lines = append(lines, r.Line())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0] != "1" || jobs[1] != "2" {
+		t.Errorf("Wrong entries: %v", jobs)
+	}
+	if lines[0] != 4 || lines[1] != 6 {
+		t.Errorf("Wrong line numbers: %v", lines)
+	}
+	if string(r.Text()) != strings.Replace(testline1, ":1:sge:", ":2:sge:", 1) {
+		t.Errorf("Wrong text of the last entry: %s", r.Text())
+	}
+	if r.Entry().WallClock != 62331.836 {
+		t.Errorf("Line ending must be removed from last column: %f", r.Entry().WallClock)
+	}
+	if r.Next() {
+		t.Error("Next must return false after the end of the input")
+	}
+}
+
+func TestReaderMaxLineLength(t *testing.T) {
+	r := NewReader(strings.NewReader("# header\n" + testline1 + "\n" + testline1 + strings.Repeat("x", 100) + "\n"))
+	r.MaxLineLength = len(testline1)
+	count := 0
+	for r.Next() {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 entry before the long line but got %d", count)
+	}
+	err, ok := r.Err().(*ParseError)
+	if !ok || err.Line != 3 {
+		t.Errorf("Expected error in line 3 but got %v", r.Err())
+	}
+}
+
+// sogeLine is an entry of a Son of Grid Engine 8.1.9 accounting file
+// with 45 columns and time stamps in seconds.
+var sogeLine = "all.q:node1:users:alice:sleep:42:sge:0:1448376371:1448376372:1448376432:0:0:60:0.5:0.25:1024:0:0:0:0:100:0:0:0:8:0:0:0:10:2:NONE:defaultdepartment:NONE:1:0:0.75:0.001:0.002:-q all.q:0.000000:NONE:268435456:0:0"
+
+func TestReaderSchemaDetection(t *testing.T) {
+	for header, expected := range map[string]*Schema{
+		"# Version: 8.1.9\n":                      SoGE,
+		"# Version: 8.1.9 (Son of Grid Engine)\n": SoGE,
+		"# Version: 6.2u5\n":                      SGE62,
+		"# Version: 8.4.0\n":                      SoGE,
+		"":                                        SGE62,
+	} {
+		r := NewReader(strings.NewReader(header + sogeLine + "\n"))
+		r.Strict = true
+		if !r.Next() {
+			t.Errorf("Header %q: %v", header, r.Err())
+			continue
+		}
+		if r.Schema != expected {
+			t.Errorf("Header %q: expected schema %s but got %s", header, expected, r.Schema)
+		}
+		if e := r.Entry(); e.JobNumber != "42" || e.EndTime.Unix() != 1448376432 || e.MaxVmem != 268435456 {
+			t.Errorf("Header %q: wrong entry %v", header, e)
+		}
+	}
+
+	r := NewReader(strings.NewReader("# Version: 8.3.1\n" + testline1 + "\n"))
+	r.Strict = true
+	if !r.Next() || r.Schema != UGE84 {
+		t.Errorf("Expected schema UGE 8.4 for 53 columns but got %s (%v)", r.Schema, r.Err())
+	}
+}
@@ -0,0 +1,178 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// columnNames are the names of the accounting file columns (see
+// accounting(5)) in the order of the Entry fields.
+var columnNames = []string{
+	"qname", "hostname", "group", "owner", "job_name", "job_number",
+	"account", "priority", "submission_time", "start_time", "end_time",
+	"failed", "exit_status", "ru_wallclock", "ru_utime", "ru_stime",
+	"ru_maxrss", "ru_ixrss", "ru_ismrss", "ru_idrss", "ru_isrss",
+	"ru_minflt", "ru_majflt", "ru_nswap", "ru_inblock", "ru_oublock",
+	"ru_msgsnd", "ru_msgrcv", "ru_nsignals", "ru_nvcsw", "ru_nivcsw",
+	"project", "department", "granted_pe", "slots", "task_number",
+	"cpu", "mem", "io", "category", "iow", "pe_taskid", "maxvmem",
+	"arid", "ar_submission_time", "job_class", "qdel_info", "maxrss",
+	"maxpss", "submit_host", "cwd", "submit_cmd", "wallclock",
+}
+
+// Schema describes the columns of the accounting file of a Grid Engine
+// version. The columns of all versions are a prefix of the Entry fields.
+type Schema struct {
+	// Name of the Grid Engine version (like "UGE 8.2")
+	Name string
+	// Columns is the number of columns of an accounting line.
+	Columns int
+	// Milliseconds is true if time stamps are written in milliseconds
+	// since epoch, otherwise in seconds.
+	Milliseconds bool
+}
+
+// Schemas of the supported Grid Engine versions.
+var (
+	// SGE62 is Sun Grid Engine 6.2 (and Open Grid Scheduler).
+	SGE62 = &Schema{Name: "SGE 6.2", Columns: 45}
+	// SoGE is Son of Grid Engine 8.1.
+	SoGE = &Schema{Name: "SoGE", Columns: 45}
+	// UGE80 is Univa Grid Engine 8.0 and 8.1 which added the job class.
+	UGE80 = &Schema{Name: "UGE 8.0", Columns: 46}
+	// UGE82 is Univa Grid Engine 8.2 and 8.3 which write time stamps
+	// in milliseconds and added the qdel info, memory usage, submit
+	// host, working directory, and submit command.
+	UGE82 = &Schema{Name: "UGE 8.2", Columns: 52, Milliseconds: true}
+	// UGE84 is Univa Grid Engine 8.4 and later which added the wall
+	// clock time in milliseconds.
+	UGE84 = &Schema{Name: "UGE 8.4", Columns: 53, Milliseconds: true}
+)
+
+// Schemas contains all supported schemas.
+var Schemas = []*Schema{SGE62, SoGE, UGE80, UGE82, UGE84}
+
+// ColumnError is returned by strict parsing when a column can't be
+// converted. Column is the column number starting with 1.
+type ColumnError struct {
+	Column int
+	Name   string
+	Value  string
+	Err    error
+}
+
+func (e *ColumnError) Error() string {
+	err := e.Err
+	if ne, isNumError := err.(*strconv.NumError); isNumError {
+		err = ne.Err
+	}
+	return fmt.Sprintf("column %d (%s): invalid value %q: %s", e.Column, e.Name, e.Value, err)
+}
+
+// ColumnNames returns the names of the columns of the schema.
+func (s *Schema) ColumnNames() []string {
+	return columnNames[:s.Columns]
+}
+
+// String returns the name of the schema.
+func (s *Schema) String() string {
+	return s.Name
+}
+
+// ParseLine parses an accounting line of the schema. In strict mode
+// an error is returned when the number of columns does not match the
+// schema or when a value can't be converted (see ColumnError). Otherwise
+// it behaves like the ParseLine function.
+func (s *Schema) ParseLine(line []byte, strict bool) (Entry, error) {
+	if !strict {
+		return ParseLine(line)
+	}
+	chunks := splitLine(line)
+	if len(chunks) != s.Columns {
+		return Entry{}, fmt.Errorf("%s accounting line has %d columns but %d are expected", s.Name, len(chunks), s.Columns)
+	}
+	return parseColumns(chunks, true, s.Milliseconds)
+}
+
+// FormatLine returns the columns of the schema of the entry as
+// accounting line (without line ending).
+func (s *Schema) FormatLine(e Entry) []byte {
+	return formatLine(e, s.Columns, s.Milliseconds)
+}
+
+// SchemaForColumns returns the schema with the given number of columns.
+// Son of Grid Engine files have the same columns like SGE 6.2 files.
+func SchemaForColumns(columns int) (*Schema, error) {
+	for _, s := range Schemas {
+		if s.Columns == columns {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no accounting schema with %d columns", columns)
+}
+
+// SchemaForVersion returns the schema of a Grid Engine version as found
+// in the "# Version:" header of the accounting file (like 8.3.1p6 or
+// 6.2u5). Versions which contain "SoGE" or "Son of Grid Engine" are Son
+// of Grid Engine, versions like 2011.11 are Open Grid Scheduler. Other
+// 8.0 and 8.1 versions are Univa Grid Engine although Son of Grid
+// Engine writes plain 8.1 versions, too. A Reader resolves this by the
+// number of columns.
+func SchemaForVersion(version string) (*Schema, error) {
+	lower := strings.ToLower(version)
+	if strings.Contains(lower, "soge") || strings.Contains(lower, "son of grid engine") {
+		return SoGE, nil
+	}
+	v := strings.TrimLeft(version, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ ")
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("unknown Grid Engine version %s", version)
+	}
+	if digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }); digits >= 0 {
+		parts[1] = parts[1][:digits]
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("unknown Grid Engine version %s", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("unknown Grid Engine version %s", version)
+	}
+	switch {
+	case major < 8 || major >= 2000:
+		return SGE62, nil
+	case major == 8 && minor < 2:
+		return UGE80, nil
+	case major == 8 && minor < 4:
+		return UGE82, nil
+	}
+	return UGE84, nil
+}
+
+// headerVersion returns the version of a "# Version: 8.3.1" header
+// comment line.
+func headerVersion(line []byte) (string, bool) {
+	comment := strings.TrimSpace(strings.TrimPrefix(string(line), "#"))
+	if !strings.HasPrefix(comment, "Version:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(comment, "Version:")), true
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnNames(t *testing.T) {
+	typ := reflect.TypeOf(Entry{})
+	if typ.Field(len(columnNames)-1).Name != "WallClock" || typ.Field(peTaskIDColumn).Name != "PETaskID" {
+		t.Errorf("Column names are not aligned with the Entry fields: %v", columnNames)
+	}
+	if UGE84.ColumnNames()[len(UGE84.ColumnNames())-1] != "wallclock" {
+		t.Errorf("Wrong columns of UGE 8.4: %v", UGE84.ColumnNames())
+	}
+}
+
+func TestSchemaForVersion(t *testing.T) {
+	for version, expected := range map[string]*Schema{
+		"6.2u5": SGE62, "2011.11p1": SGE62, "8.1.9 (SoGE)": SoGE, "Son of Grid Engine 8.1.9": SoGE,
+		"8.0.1": UGE80, "8.1.7p3": UGE80, "8.2.0": UGE82, "8.3.1p6": UGE82, "8.4.0": UGE84,
+		"8.6.12": UGE84, "UGE 8.4": UGE84, "SGE 6.2": SGE62,
+	} {
+		s, err := SchemaForVersion(version)
+		if err != nil {
+			t.Errorf("Version %s: %s", version, err)
+			continue
+		}
+		if s != expected {
+			t.Errorf("Version %s: expected schema %s but got %s", version, expected, s)
+		}
+	}
+	if _, err := SchemaForVersion("unknown"); err == nil {
+		t.Error("Expected error for unknown version")
+	}
+}
+
+func TestSchemaParseLineStrict(t *testing.T) {
+	e, err := UGE84.ParseLine([]byte(testline1), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.RuWallclock != 62331.676 || e.EndTime.Unix() != 1448438703 {
+		t.Errorf("Wrong entry: %v", e)
+	}
+
+	if _, err := UGE82.ParseLine([]byte(testline1), true); err == nil {
+		t.Error("Expected error since UGE 8.2 lines have 52 columns")
+	}
+
+	broken := strings.Replace(testline1, ":100:137:", ":100:abc:", 1)
+	_, err = UGE84.ParseLine([]byte(broken), true)
+	ce, isColumnError := err.(*ColumnError)
+	if !isColumnError || ce.Column != 13 || ce.Name != "exit_status" || ce.Value != "abc" {
+		t.Fatalf("Expected column error for exit_status but got %v", err)
+	}
+	if err.Error() != `column 13 (exit_status): invalid value "abc": invalid syntax` {
+		t.Errorf("Wrong error message: %s", err)
+	}
+	if _, err := UGE84.ParseLine([]byte(broken), false); err != nil {
+		t.Errorf("Non strict parsing must ignore invalid values: %s", err)
+	}
+}
+
+func TestReaderStrict(t *testing.T) {
+	// SGE 6.2 line with seconds
+	line := strings.Join(strings.Split(testline1, ":")[:45], ":")
+	line = strings.Replace(line, "1448438703533", "1448438703", 1)
+
+	r := NewReader(strings.NewReader("# Version: 6.2u5\n" + line + "\n" + testline1 + "\n"))
+	r.Strict = true
+	if !r.Next() || r.Schema != SGE62 || r.Entry().EndTime.Unix() != 1448438703 {
+		t.Fatalf("Wrong first entry (%s): %v", r.Schema, r.Entry())
+	}
+	if r.Next() {
+		t.Fatal("Expected error since second line has 53 columns")
+	}
+	if pe, isParseError := r.Err().(*ParseError); !isParseError || pe.Line != 3 {
+		t.Errorf("Expected error in line 3 but got %v", r.Err())
+	}
+
+	// detection by column count
+	r = NewReader(strings.NewReader(testline1 + "\n"))
+	r.Strict = true
+	if !r.Next() || r.Schema != UGE84 {
+		t.Errorf("Expected UGE 8.4 schema but got %s (%v)", r.Schema, r.Err())
+	}
+	r = NewReader(strings.NewReader("a:b:c\n"))
+	r.Strict = true
+	if r.Next() || r.Err() == nil {
+		t.Error("Expected error for unknown number of columns")
+	}
+}
@@ -0,0 +1,284 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SummaryKey is an attribute by which entries are grouped in a summary
+// like the qacct options -o, -g, -P, -D, -q, -h, and -pe do.
+type SummaryKey int
+
+const (
+	// SummaryOwner groups by the job owner (qacct -o).
+	SummaryOwner SummaryKey = iota
+	// SummaryGroup groups by the UNIX group (qacct -g).
+	SummaryGroup
+	// SummaryProject groups by the project (qacct -P).
+	SummaryProject
+	// SummaryDepartment groups by the department (qacct -D).
+	SummaryDepartment
+	// SummaryQueue groups by the cluster queue (qacct -q).
+	SummaryQueue
+	// SummaryHost groups by the execution host (qacct -h).
+	SummaryHost
+	// SummaryPE groups by the granted parallel environment (qacct -pe).
+	SummaryPE
+)
+
+var summaryKeyNames = []string{"OWNER", "GROUP", "PROJECT", "DEPARTMENT", "CLUSTER QUEUE", "HOST", "PE"}
+
+// String returns the column header of the key like qacct prints it.
+func (k SummaryKey) String() string {
+	if k < 0 || int(k) >= len(summaryKeyNames) {
+		return fmt.Sprintf("SummaryKey(%d)", int(k))
+	}
+	return summaryKeyNames[k]
+}
+
+// Value returns the value of the key of the entry.
+func (k SummaryKey) Value(e Entry) string {
+	switch k {
+	case SummaryOwner:
+		return e.Owner
+	case SummaryGroup:
+		return e.GroupID
+	case SummaryProject:
+		return e.Project
+	case SummaryDepartment:
+		return e.Department
+	case SummaryQueue:
+		return e.Qname
+	case SummaryHost:
+		return e.Hostname
+	case SummaryPE:
+		return e.GrantedPE
+	}
+	return ""
+}
+
+// Usage is the accumulated resource usage of accounting entries.
+type Usage struct {
+	// Entries is the number of accounting entries.
+	Entries int
+	// WallClock is the sum of ru_wallclock in seconds.
+	WallClock float64
+	// UTime and STime are the sums of the user and system time in
+	// seconds.
+	UTime float64
+	STime float64
+	// CPU is the sum of the cpu usage in seconds.
+	CPU float64
+	// Memory is the integral memory usage in GB seconds.
+	Memory float64
+	// IO is the amount of data transferred in GB.
+	IO float64
+	// IOW is the io wait time in seconds.
+	IOW float64
+}
+
+// Add adds the usage of the entry.
+func (u *Usage) Add(e Entry) {
+	u.Entries++
+	u.WallClock += e.RuWallclock
+	u.UTime += e.RuUtime
+	u.STime += e.RuStime
+	u.CPU += e.CPU
+	u.Memory += e.MEM
+	u.IO += e.IO
+	u.IOW += e.IOW
+}
+
+// SummaryOptions select and group the entries of a summary.
+type SummaryOptions struct {
+	// Keys are the attributes by which the entries are grouped. Without
+	// keys only the total usage is computed.
+	Keys []SummaryKey
+	// Begin selects only jobs which started at or after Begin (qacct
+	// -b) when not zero.
+	Begin time.Time
+	// End selects only jobs which started before End (qacct -e) when
+	// not zero.
+	End time.Time
+	// Resources selects only jobs which requested all of the resources
+	// (qacct -l). The requests are taken from the category and the
+	// submit command. An empty value matches any requested value.
+	Resources map[string]string
+	// Filter selects the entries when not nil.
+	Filter func(Entry) bool
+}
+
+// SummaryRow is the usage of the entries with the same key values.
+type SummaryRow struct {
+	// Values of the keys in the order of the SummaryOptions keys
+	Values []string
+	Usage
+}
+
+// Summary is the usage of accounting entries grouped by keys.
+type Summary struct {
+	Keys []SummaryKey
+	// Rows are sorted by the key values.
+	Rows []SummaryRow
+	// Total is the usage of all selected entries.
+	Total Usage
+}
+
+// Summarizer accumulates the usage of accounting entries.
+//
+//	s := accounting.NewSummarizer(accounting.SummaryOptions{
+//	    Keys: []accounting.SummaryKey{accounting.SummaryOwner}})
+//	for r.Next() {
+//	    s.Add(r.Entry())
+//	}
+//	fmt.Print(s.Summary())
+type Summarizer struct {
+	opts  SummaryOptions
+	rows  map[string]*SummaryRow
+	total Usage
+}
+
+// NewSummarizer returns a Summarizer.
+func NewSummarizer(opts SummaryOptions) *Summarizer {
+	return &Summarizer{opts: opts, rows: make(map[string]*SummaryRow)}
+}
+
+// Add accumulates the usage of the entry. It returns false when the
+// entry is not selected by the options.
+func (s *Summarizer) Add(e Entry) bool {
+	if !s.selected(e) {
+		return false
+	}
+	s.total.Add(e)
+	if len(s.opts.Keys) == 0 {
+		return true
+	}
+	values := make([]string, len(s.opts.Keys))
+	for i, k := range s.opts.Keys {
+		values[i] = k.Value(e)
+	}
+	id := strings.Join(values, "\x00")
+	row, exists := s.rows[id]
+	if !exists {
+		row = &SummaryRow{Values: values}
+		s.rows[id] = row
+	}
+	row.Add(e)
+	return true
+}
+
+func (s *Summarizer) selected(e Entry) bool {
+	if !s.opts.Begin.IsZero() && e.StartTime.Before(s.opts.Begin) {
+		return false
+	}
+	if !s.opts.End.IsZero() && !e.StartTime.Before(s.opts.End) {
+		return false
+	}
+	if len(s.opts.Resources) > 0 && !requested(e, s.opts.Resources) {
+		return false
+	}
+	return s.opts.Filter == nil || s.opts.Filter(e)
+}
+
+// requested checks that the job requested all resources.
+func requested(e Entry, resources map[string]string) bool {
+	complexes := requestedComplexes(e)
+	for name, value := range resources {
+		got, exists := complexes[name]
+		if !exists || (value != "" && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary returns the usage accumulated so far.
+func (s *Summarizer) Summary() Summary {
+	sum := Summary{Keys: s.opts.Keys, Total: s.total, Rows: make([]SummaryRow, 0, len(s.rows))}
+	for _, row := range s.rows {
+		sum.Rows = append(sum.Rows, *row)
+	}
+	sort.Slice(sum.Rows, func(i, j int) bool {
+		a, b := sum.Rows[i].Values, sum.Rows[j].Values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return sum
+}
+
+// Summarize accumulates the usage of the entries of the channel (like
+// the Entries of a ParallelReader) until it is closed.
+func Summarize(entries <-chan Entry, opts SummaryOptions) Summary {
+	s := NewSummarizer(opts)
+	for e := range entries {
+		s.Add(e)
+	}
+	return s.Summary()
+}
+
+// String renders the summary as table like qacct does. Without keys
+// the total usage is printed as "Total System Usage".
+func (s Summary) String() string {
+	var buf bytes.Buffer
+	widths := make([]int, len(s.Keys))
+	for i, k := range s.Keys {
+		widths[i] = len(k.String())
+		for _, row := range s.Rows {
+			if len(row.Values[i]) > widths[i] {
+				widths[i] = len(row.Values[i])
+			}
+		}
+	}
+	var header bytes.Buffer
+	if len(s.Keys) == 0 {
+		buf.WriteString("Total System Usage\n")
+	}
+	for i, k := range s.Keys {
+		fmt.Fprintf(&header, "%-*s ", widths[i], k)
+	}
+	fmt.Fprintf(&header, "%13s %13s %13s %13s %18s %18s %18s",
+		"WALLCLOCK", "UTIME", "STIME", "CPU", "MEMORY", "IO", "IOW")
+	buf.Write(header.Bytes())
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Repeat("=", header.Len()))
+	buf.WriteByte('\n')
+	if len(s.Keys) == 0 {
+		writeUsage(&buf, s.Total)
+		return buf.String()
+	}
+	for _, row := range s.Rows {
+		for i, value := range row.Values {
+			fmt.Fprintf(&buf, "%-*s ", widths[i], value)
+		}
+		writeUsage(&buf, row.Usage)
+	}
+	return buf.String()
+}
+
+func writeUsage(buf *bytes.Buffer, u Usage) {
+	fmt.Fprintf(buf, "%13.0f %13.3f %13.3f %13.3f %18.3f %18.3f %18.3f\n",
+		u.WallClock, u.UTime, u.STime, u.CPU, u.Memory, u.IO, u.IOW)
+}
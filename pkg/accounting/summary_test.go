@@ -0,0 +1,126 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var summaryEntries = []Entry{
+	{Owner: "alice", Project: "p1", Qname: "all.q", RuWallclock: 10, RuUtime: 8, CPU: 9, MEM: 1.5,
+		StartTime: time.Unix(1000, 0), EndTime: time.Unix(1010, 0), Category: "-l h_vmem=4G -q all.q"},
+	{Owner: "alice", Project: "p2", Qname: "all.q", RuWallclock: 20, RuUtime: 18, CPU: 19, MEM: 2.5,
+		StartTime: time.Unix(2000, 0), EndTime: time.Unix(2020, 0)},
+	{Owner: "bob", Project: "p1", Qname: "gpu.q", RuWallclock: 30, RuStime: 2, CPU: 29, IO: 0.5,
+		StartTime: time.Unix(3000, 0), EndTime: time.Unix(3030, 0),
+		SubmitCommand: "qsub -l gpu=1,h_vmem=8G job.sh"},
+}
+
+func summarize(opts SummaryOptions) Summary {
+	s := NewSummarizer(opts)
+	for _, e := range summaryEntries {
+		s.Add(e)
+	}
+	return s.Summary()
+}
+
+func TestSummaryTotal(t *testing.T) {
+	sum := summarize(SummaryOptions{})
+	if sum.Total.Entries != 3 || sum.Total.WallClock != 60 || sum.Total.CPU != 57 || sum.Total.Memory != 4 {
+		t.Errorf("Wrong total: %+v", sum.Total)
+	}
+	if !strings.HasPrefix(sum.String(), "Total System Usage\n") {
+		t.Errorf("Wrong rendering: %s", sum)
+	}
+}
+
+func TestSummaryKeys(t *testing.T) {
+	sum := summarize(SummaryOptions{Keys: []SummaryKey{SummaryOwner, SummaryProject}})
+	if len(sum.Rows) != 3 {
+		t.Fatalf("Expected 3 rows but got %d", len(sum.Rows))
+	}
+	if strings.Join(sum.Rows[1].Values, ",") != "alice,p2" || sum.Rows[1].WallClock != 20 {
+		t.Errorf("Wrong row: %+v", sum.Rows[1])
+	}
+	lines := strings.Split(sum.String(), "\n")
+	if !strings.HasPrefix(lines[0], "OWNER PROJECT") || !strings.HasPrefix(lines[1], "=====") ||
+		!strings.HasPrefix(lines[4], "bob   p1") {
+		t.Errorf("Wrong rendering:\n%s", sum)
+	}
+
+	sum = summarize(SummaryOptions{Keys: []SummaryKey{SummaryOwner}})
+	if len(sum.Rows) != 2 || sum.Rows[0].Entries != 2 || sum.Rows[0].UTime != 26 {
+		t.Errorf("Wrong rows: %+v", sum.Rows)
+	}
+}
+
+func TestSummarySelection(t *testing.T) {
+	sum := summarize(SummaryOptions{Begin: time.Unix(2000, 0), End: time.Unix(3000, 0)})
+	if sum.Total.Entries != 1 || sum.Total.WallClock != 20 {
+		t.Errorf("Time window selected wrong entries: %+v", sum.Total)
+	}
+	// like qacct -e the end of the window applies to the start time
+	sum = summarize(SummaryOptions{End: time.Unix(3010, 0)})
+	if sum.Total.Entries != 3 {
+		t.Errorf("Expected the job which started before the end to be selected: %+v", sum.Total)
+	}
+	sum = summarize(SummaryOptions{Resources: map[string]string{"h_vmem": ""}})
+	if sum.Total.Entries != 2 {
+		t.Errorf("Expected 2 entries with h_vmem request but got %d", sum.Total.Entries)
+	}
+	sum = summarize(SummaryOptions{Resources: map[string]string{"h_vmem": "8G", "gpu": "1"}})
+	if sum.Total.Entries != 1 || sum.Total.IO != 0.5 {
+		t.Errorf("Wrong entries for resource requests: %+v", sum.Total)
+	}
+	sum = summarize(SummaryOptions{Filter: func(e Entry) bool { return e.Qname == "gpu.q" }})
+	if sum.Total.Entries != 1 {
+		t.Errorf("Filter selected wrong entries: %+v", sum.Total)
+	}
+}
+
+func TestSummaryFractions(t *testing.T) {
+	e, err := ParseLine([]byte(testline1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSummarizer(SummaryOptions{})
+	for i := 0; i < 10; i++ {
+		s.Add(e)
+	}
+	if u := s.Summary().Total; math.Abs(u.WallClock-623316.76) > 1e-6 || math.Abs(u.UTime-1.21) > 1e-9 ||
+		math.Abs(u.STime-0.61) > 1e-9 {
+		t.Errorf("Expected fractions of ru_wallclock, ru_utime, and ru_stime to be accumulated: %+v", u)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	entries := make(chan Entry, len(summaryEntries))
+	for _, e := range summaryEntries {
+		entries <- e
+	}
+	close(entries)
+	sum := Summarize(entries, SummaryOptions{Keys: []SummaryKey{SummaryQueue}})
+	if len(sum.Rows) != 2 || sum.Rows[1].Values[0] != "gpu.q" {
+		t.Errorf("Wrong rows: %+v", sum.Rows)
+	}
+	if SummaryQueue.String() != "CLUSTER QUEUE" {
+		t.Errorf("Wrong key name %s", SummaryQueue)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatLine returns the entry as line of an accounting file (without
+// line ending). All columns are written and time stamps are written in
+// milliseconds since epoch like Univa Grid Engine 8.4 and later does.
+func FormatLine(e Entry) []byte {
+	return UGE84.FormatLine(e)
+}
+
+// formatLine writes the first columns fields of the entry in the order
+// of the Entry struct. A ':' within a value is encoded as \xFF, a newline
+// is replaced by a space since it would terminate the line. The
+// pe_taskid is written as RawPETaskID when it matches PETaskID.
+func formatLine(e Entry, columns int, ms bool) []byte {
+	var buf bytes.Buffer
+	v := reflect.ValueOf(e)
+	for i := 0; i < len(columnNames) && i < columns; i++ {
+		if i > 0 {
+			buf.WriteByte(':')
+		}
+		if i == peTaskIDColumn {
+			buf.WriteString(formatPETaskID(e))
+			continue
+		}
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			s := strings.Replace(field.String(), ":", "\xFF", -1)
+			buf.WriteString(strings.Replace(s, "\n", " ", -1))
+		case reflect.Int64, reflect.Int:
+			buf.WriteString(strconv.FormatInt(field.Int(), 10))
+		case reflect.Float64:
+			buf.WriteString(strconv.FormatFloat(field.Float(), 'f', -1, 64))
+		case reflect.Struct:
+			if t, isTime := field.Interface().(time.Time); isTime {
+				buf.WriteString(formatTime(t, ms))
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// formatPETaskID returns the raw PE task id unless PETaskID was changed.
+// Entries which are not PE subtasks have the PE task id NONE.
+func formatPETaskID(e Entry) string {
+	if e.RawPETaskID != "" {
+		if id, err := parseInt([]byte(e.RawPETaskID)); err == nil && int(id) == e.PETaskID {
+			return strings.Replace(e.RawPETaskID, ":", "\xFF", -1)
+		}
+	}
+	if e.PETaskID == 0 {
+		return "NONE"
+	}
+	return strconv.Itoa(e.PETaskID)
+}
+
+func formatTime(t time.Time, ms bool) string {
+	if t.IsZero() || t.Unix() == 0 {
+		return "0"
+	}
+	if ms {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// Writer writes entries in the accounting file format.
+type Writer struct {
+	// Version is the Grid Engine version of the accounting file (like
+	// 8.3.1). When set, a header with the version is written before the
+	// first entry.
+	Version string
+	// Schema determines the columns and whether time stamps are written
+	// in seconds or milliseconds. When nil the schema of the Version is
+	// used (see SchemaForVersion) or, without version, UGE84.
+	Schema *Schema
+
+	w       *bufio.Writer
+	started bool
+}
+
+// NewWriter returns a Writer which writes to w. Flush must be called
+// after the last entry.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Write writes the entry as one line.
+func (w *Writer) Write(e Entry) error {
+	if !w.started {
+		if w.Schema == nil {
+			w.Schema = UGE84
+			if w.Version != "" {
+				s, err := SchemaForVersion(w.Version)
+				if err != nil {
+					return err
+				}
+				w.Schema = s
+			}
+		}
+		w.started = true
+		if w.Version != "" {
+			if _, err := fmt.Fprintf(w.w, "# Version: %s\n# \n# DO NOT MODIFY THIS FILE MANUALLY!\n# \n", w.Version); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := w.w.Write(w.Schema.FormatLine(e)); err != nil {
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+// Flush writes buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
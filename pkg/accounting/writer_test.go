@@ -0,0 +1,101 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package accounting
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLineRoundTrip(t *testing.T) {
+	entry, _ := ParseLine([]byte(testline1))
+	entry.SubmitCommand = "qsub -l h=node1 -o /tmp/out:/tmp/err job.sh"
+	entry.Category = "-l hostname=a:b"
+
+	line := FormatLine(entry)
+	if bytes.Count(line, []byte(":")) != len(columnNames)-1 {
+		t.Errorf("':' within values must be escaped: %s", line)
+	}
+	parsed, err := ParseLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entry, parsed) {
+		t.Errorf("Round trip failed:\n%v\n%v", entry, parsed)
+	}
+	if parsed.EndTime.UnixNano() != 1448438703533*int64(time.Millisecond) {
+		t.Errorf("Milliseconds of end time are lost: %v", parsed.EndTime)
+	}
+}
+
+func peTaskIDOf(line []byte) string {
+	return string(splitLine(line)[peTaskIDColumn])
+}
+
+func TestFormatLinePETaskID(t *testing.T) {
+	for raw, expected := range map[string]int{"NONE": 0, "1.node1": 1, "7": 7} {
+		line := strings.Replace(testline1, ":0.000000:NONE:", ":0.000000:"+raw+":", 1)
+		entry, _ := ParseLine([]byte(line))
+		if entry.PETaskID != expected || entry.RawPETaskID != raw {
+			t.Errorf("Expected PE task id %d (%s) but got %d (%s)", expected, raw, entry.PETaskID, entry.RawPETaskID)
+		}
+		if column := peTaskIDOf(FormatLine(entry)); column != raw {
+			t.Errorf("Expected PE task id %s to be written verbatim but got %s", raw, column)
+		}
+	}
+	if column := peTaskIDOf(FormatLine(Entry{})); column != "NONE" {
+		t.Errorf("Expected NONE as PE task id of an entry without PE task but got %s", column)
+	}
+	entry, _ := ParseLine([]byte(strings.Replace(testline1, ":0.000000:NONE:", ":0.000000:1.node1:", 1)))
+	entry.PETaskID = 2
+	if column := peTaskIDOf(FormatLine(entry)); column != "2" {
+		t.Errorf("A changed PE task id must be written but got %s", column)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	entry, _ := ParseLine([]byte(testline1))
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Version = "6.2u5"
+	w.Write(entry)
+	w.Write(entry)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "# Version: 6.2u5\n") || strings.Count(buf.String(), "# Version") != 1 {
+		t.Errorf("Wrong header:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), ":1448376371:1448376371:1448438703:") {
+		t.Errorf("Time stamps must be in seconds for 6.2u5:\n%s", buf.String())
+	}
+
+	r := NewReader(&buf)
+	count := 0
+	for r.Next() {
+		count++
+		if r.Entry().EndTime.Unix() != entry.EndTime.Unix() || r.Entry().JobNumber != entry.JobNumber {
+			t.Errorf("Wrong entry read back: %v", r.Entry())
+		}
+	}
+	if count != 2 || r.Err() != nil {
+		t.Errorf("Expected 2 entries but got %d (%v)", count, r.Err())
+	}
+}
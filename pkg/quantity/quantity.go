@@ -0,0 +1,62 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package quantity parses the numeric values of Grid Engine like 4G or
+// INFINITY. It has no dependencies so that all packages can share it.
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// multipliers are the Grid Engine memory units. Lower case units are
+// based on 1000, upper case units on 1024.
+var multipliers = map[byte]float64{
+	'k': 1e3,
+	'm': 1e6,
+	'g': 1e9,
+	't': 1e12,
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+}
+
+// Parse parses a numeric Grid Engine value like 4G, 1.5k,
+// 64314.000000M or INFINITY and returns it as plain number. Memory
+// units are resolved into bytes.
+func Parse(value string) (float64, error) {
+	v := strings.TrimSpace(value)
+	if strings.EqualFold(v, "INFINITY") {
+		return math.Inf(1), nil
+	}
+	if v == "" {
+		return 0, fmt.Errorf("empty quantity")
+	}
+	multiplier := 1.0
+	if m, exists := multipliers[v[len(v)-1]]; exists {
+		multiplier = m
+		v = v[:len(v)-1]
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("quantity %s is not numeric", value)
+	}
+	return f * multiplier, nil
+}
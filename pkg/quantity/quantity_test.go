@@ -0,0 +1,38 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package quantity
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for value, expected := range map[string]float64{
+		"1": 1, "1.5k": 1500, "2K": 2048, "4G": 4 << 30, "1m": 1e6, " 8g ": 8e9,
+		"64314.000000M": 64314 << 20, "INFINITY": math.Inf(1), "infinity": math.Inf(1),
+	} {
+		if q, err := Parse(value); err != nil || q != expected {
+			t.Errorf("Expected %f for %q but got %f (%v)", expected, value, q, err)
+		}
+	}
+	for _, value := range []string{"", "abc", "4X"} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
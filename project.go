@@ -0,0 +1,123 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Project is a Univa Grid Engine project as shown by qconf -sprj.
+// ACL and XACL contain the names of the user lists which are allowed
+// respectively not allowed to submit jobs into the project.
+type Project struct {
+	Name    string
+	OTicket int
+	FShare  int
+	ACL     []string
+	XACL    []string
+}
+
+// ParseProject parses a project in the format of qconf -sprj:
+// name    project1
+// oticket 0
+// fshare  0
+// acl     deadlineusers
+// xacl    NONE
+func ParseProject(prj string) (*Project, error) {
+	attrs, err := parseAttributes(prj)
+	if err != nil {
+		return nil, err
+	}
+	var p Project
+	for _, a := range attrs {
+		switch a.name {
+		case "name":
+			p.Name = a.value
+		case "oticket":
+			p.OTicket, err = strconv.Atoi(a.value)
+		case "fshare":
+			p.FShare, err = strconv.Atoi(a.value)
+		case "acl":
+			p.ACL = splitList(a.value)
+		case "xacl":
+			p.XACL = splitList(a.value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error during %s parsing: %s", a.name, err)
+		}
+	}
+	if p.Name == "" {
+		return nil, errors.New("project has no name")
+	}
+	return &p, nil
+}
+
+// String returns the project in the qconf file format.
+func (p Project) String() string {
+	return formatAttributes([]attribute{
+		{"name", p.Name},
+		{"oticket", strconv.Itoa(p.OTicket)},
+		{"fshare", strconv.Itoa(p.FShare)},
+		{"acl", joinList(p.ACL)},
+		{"xacl", joinList(p.XACL)},
+	})
+}
+
+// GetProjectList calls qconf -sprjl and returns the names of all
+// projects.
+func GetProjectList() ([]string, error) {
+	return qconfList("-sprjl")
+}
+
+// GetProjects calls qconf -sprj <name> for each given project and
+// parses the output into Project structs.
+func GetProjects(names ...string) ([]Project, error) {
+	projects := make([]Project, 0, len(names))
+	for _, name := range names {
+		out, err := qconf("-sprj", name)
+		if err != nil {
+			return nil, err
+		}
+		p, err := ParseProject(string(out))
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, *p)
+	}
+	return projects, nil
+}
+
+// AddProject creates the project in the cluster (qconf -Aprj).
+func AddProject(p Project) error {
+	return qconfFromFile("-Aprj", p.String())
+}
+
+// ModifyProject replaces the configuration of an existing project
+// (qconf -Mprj).
+func ModifyProject(p Project) error {
+	return qconfFromFile("-Mprj", p.String())
+}
+
+// DeleteProjects removes the given projects from the cluster
+// (qconf -dprj).
+func DeleteProjects(names ...string) error {
+	_, err := qconf("-dprj", strings.Join(names, ","))
+	return err
+}
@@ -0,0 +1,58 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+)
+
+func TestParseProject(t *testing.T) {
+	prj := `name    project1
+oticket 10
+fshare  100
+acl     deadlineusers,arusers
+xacl    NONE`
+	p, err := ParseProject(prj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "project1" {
+		t.Errorf("Name is not project1, it is %s", p.Name)
+	}
+	if p.OTicket != 10 {
+		t.Errorf("OTicket is not 10, it is %d", p.OTicket)
+	}
+	if p.FShare != 100 {
+		t.Errorf("FShare is not 100, it is %d", p.FShare)
+	}
+	if len(p.ACL) != 2 || p.ACL[1] != "arusers" {
+		t.Errorf("Wrong ACL: %v", p.ACL)
+	}
+	if len(p.XACL) != 0 {
+		t.Errorf("XACL must be empty: %v", p.XACL)
+	}
+	p2, err := ParseProject(p.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p2.String() != p.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", p, p2)
+	}
+	if _, err := ParseProject("name project1\nfshare abc"); err == nil {
+		t.Error("Expected error for non numeric fshare")
+	}
+}
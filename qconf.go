@@ -0,0 +1,362 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// attribute is one "name value" line of a Grid Engine configuration
+// object as printed by qconf -s<object>.
+type attribute struct {
+	name  string
+	value string
+}
+
+// qconfPath returns the path to the qconf binary within $SGE_ROOT.
+func qconfPath() (string, error) {
+	rootPath := os.Getenv("SGE_ROOT")
+	if rootPath == "" {
+		return "", errors.New("$SGE_ROOT environment variable not set")
+	}
+	return fmt.Sprintf("%s/bin/lx-amd64/qconf", rootPath), nil
+}
+
+// QconfError is returned when qconf exits with an error. Message
+// contains what qconf printed on standard error.
+type QconfError struct {
+	Args    []string
+	Message string
+	Err     error
+}
+
+func (e *QconfError) Error() string {
+	return fmt.Sprintf("qconf %s: %s (%s)", strings.Join(e.Args, " "), e.Message, e.Err)
+}
+
+// notDefinedMessages match the messages of qconf for objects which do
+// not exist, like "no project list defined", "no sharetree element",
+// `project "x" does not exist`, or "No cluster queue or queue instance
+// matches the phrase "x"". Other errors (like "no permission ...") do not
+// match.
+var notDefinedMessages = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^no [a-z ]*(defined|element)\b`),
+	regexp.MustCompile(`(?i)\bdoes not exist\b`),
+	regexp.MustCompile(`(?i)^no cluster queue or queue instance matches`),
+}
+
+// notDefined returns true if qconf failed because the requested objects
+// do not exist (see notDefinedMessages).
+func notDefined(err error) bool {
+	qe, ok := err.(*QconfError)
+	if !ok {
+		return false
+	}
+	for _, re := range notDefinedMessages {
+		if re.MatchString(qe.Message) {
+			return true
+		}
+	}
+	return false
+}
+
+// qconf executes qconf with the given arguments and returns its
+// standard output. It is a variable so that tests can replace it.
+var qconf = execQconf
+
+// execQconf executes the qconf binary of $SGE_ROOT. In case of an
+// error the message qconf printed is part of the returned QconfError.
+func execQconf(args ...string) ([]byte, error) {
+	path, err := qconfPath()
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		log.Printf("Error during qconf %s: %s\n", strings.Join(args, " "), msg)
+		return nil, &QconfError{Args: args, Message: msg, Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+// qconfList executes a qconf list command (like qconf -shgrpl) and
+// returns the names printed one per line. When no object is defined
+// an empty list is returned.
+func qconfList(option string) ([]string, error) {
+	out, err := qconf(option)
+	if notDefined(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// parseAttributes splits the output of a qconf -s<object> command into
+// its attributes. Lines ending with a backslash are continued on the next
+// line, lines starting with # are comments.
+func parseAttributes(object string) ([]attribute, error) {
+	var attrs []attribute
+	var current string
+	lines := strings.Split(object, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\")
+			if i != len(lines)-1 {
+				continue
+			}
+		} else {
+			current += line
+		}
+		entry := strings.TrimSpace(current)
+		current = ""
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		sep := strings.IndexAny(entry, " \t")
+		if sep < 0 {
+			return nil, fmt.Errorf("attribute %s has no value", entry)
+		}
+		attrs = append(attrs, attribute{
+			name:  entry[:sep],
+			value: normalizeValue(entry[sep+1:]),
+		})
+	}
+	return attrs, nil
+}
+
+// normalizeValue removes the surrounding white space of a value and
+// collapses the white space which remains from continuation lines.
+func normalizeValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// attributeMap returns the attributes as map indexed by attribute name.
+func attributeMap(attrs []attribute) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.name] = a.value
+	}
+	return m
+}
+
+// splitList splits a Grid Engine list value which can be separated by
+// commas and/or white spaces. NONE is returned as empty list.
+func splitList(value string) []string {
+	if value == "" || strings.EqualFold(value, "NONE") {
+		return nil
+	}
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// qconfFromFile writes the given object in qconf file format into a
+// temporary file and executes qconf with the given option (like -Aprj)
+// and the file name.
+func qconfFromFile(option, object string) error {
+	file, err := ioutil.TempFile("", "ugego")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(object); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	_, err = qconf(option, file.Name())
+	return err
+}
+
+// formatAttributes returns the attributes in the qconf file format
+// with aligned values.
+func formatAttributes(attrs []attribute) string {
+	width := 0
+	for _, a := range attrs {
+		if len(a.name) > width {
+			width = len(a.name)
+		}
+	}
+	var buf bytes.Buffer
+	for _, a := range attrs {
+		fmt.Fprintf(&buf, "%-*s %s\n", width, a.name, a.value)
+	}
+	return buf.String()
+}
+
+// joinList returns a list in Grid Engine format. An empty list is NONE.
+func joinList(list []string) string {
+	if len(list) == 0 {
+		return "NONE"
+	}
+	return strings.Join(list, ",")
+}
+
+// configField binds a configuration attribute name to a pointer to
+// the struct field which holds the decoded value. Supported are
+// pointers to string, int, float64, bool, time.Duration,
+// map[string]float64 (like usage_weight_list), and map[string]string
+// (like params).
+type configField struct {
+	name  string
+	value interface{}
+}
+
+// decodeFields decodes the attributes into the bound fields. Attributes
+// without a bound field are returned in the map of other attributes.
+func decodeFields(attrs []attribute, fields []configField) (map[string]string, error) {
+	byName := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		byName[f.name] = f.value
+	}
+	other := make(map[string]string)
+	for _, a := range attrs {
+		ptr, exists := byName[a.name]
+		if !exists {
+			other[a.name] = a.value
+			continue
+		}
+		if err := decodeField(ptr, a.value); err != nil {
+			return nil, fmt.Errorf("error during %s parsing: %s", a.name, err)
+		}
+	}
+	return other, nil
+}
+
+func decodeField(ptr interface{}, value string) error {
+	var err error
+	switch v := ptr.(type) {
+	case *string:
+		*v = value
+	case *int:
+		*v, err = strconv.Atoi(value)
+	case *float64:
+		*v, err = strconv.ParseFloat(value, 64)
+	case *bool:
+		*v, err = strconv.ParseBool(strings.ToLower(value))
+	case *time.Duration:
+		*v, err = ParseTimeValue(value)
+	case *map[string]float64:
+		*v, err = parseScaling(value)
+	case *map[string]string:
+		*v = parseParams(value)
+	default:
+		err = fmt.Errorf("unsupported field type %T", ptr)
+	}
+	return err
+}
+
+// encodeFields returns the attributes of the bound fields followed by
+// the other attributes sorted by name.
+func encodeFields(fields []configField, other map[string]string) []attribute {
+	attrs := make([]attribute, 0, len(fields)+len(other))
+	for _, f := range fields {
+		attrs = append(attrs, attribute{f.name, encodeField(f.value)})
+	}
+	names := make([]string, 0, len(other))
+	for name := range other {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attrs = append(attrs, attribute{name, other[name]})
+	}
+	return attrs
+}
+
+func encodeField(ptr interface{}) string {
+	switch v := ptr.(type) {
+	case *string:
+		if *v == "" {
+			return "NONE"
+		}
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *float64:
+		return strconv.FormatFloat(*v, 'f', 6, 64)
+	case *bool:
+		if *v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case *time.Duration:
+		return FormatTimeValue(*v)
+	case *map[string]float64:
+		list := make([]string, 0, len(*v))
+		for k, f := range *v {
+			list = append(list, k+"="+strconv.FormatFloat(f, 'f', 6, 64))
+		}
+		sort.Strings(list)
+		return joinList(list)
+	case *map[string]string:
+		return formatParams(*v)
+	}
+	return ""
+}
+
+// parseParams parses a params list like "MONITOR=1,PROFILE=true
+// DURATION_OFFSET=60" which is separated by commas or white spaces.
+// Parameters without a value (like MONITOR) get an empty value.
+func parseParams(value string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range splitList(value) {
+		if eq := strings.Index(p, "="); eq >= 0 {
+			params[p[:eq]] = p[eq+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// formatParams returns the params sorted by name. Empty params are NONE.
+func formatParams(params map[string]string) string {
+	list := make([]string, 0, len(params))
+	for k, v := range params {
+		if v == "" {
+			list = append(list, k)
+		} else {
+			list = append(list, k+"="+v)
+		}
+	}
+	sort.Strings(list)
+	return joinList(list)
+}
@@ -0,0 +1,183 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// QquotaOptions are the filters of the qquota command. Empty lists
+// are not passed to qquota.
+type QquotaOptions struct {
+	Users    []string // -u
+	Projects []string // -P
+	Queues   []string // -q
+	Hosts    []string // -h
+	PEs      []string // -pe
+}
+
+// QquotaLimit is the limit of one resource within a resource quota rule
+// and its current usage. Value is empty for static limits.
+type QquotaLimit struct {
+	Resource string `xml:"resource,attr"`
+	Limit    string `xml:"limit,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+// String returns the usage of the limit like slots=40/50.
+func (l QquotaLimit) String() string {
+	if l.Value == "" {
+		return fmt.Sprintf("%s=%s", l.Resource, l.Limit)
+	}
+	return fmt.Sprintf("%s=%s/%s", l.Resource, l.Value, l.Limit)
+}
+
+// QquotaRule represents an entry out of the qquota -xml command. Name
+// is the rule name like max_per_user/1, the filters contain the rule
+// instance the usage is reported for.
+type QquotaRule struct {
+	Name     string
+	Users    []string
+	Projects []string
+	PEs      []string
+	Queues   []string
+	Hosts    []string
+	Limits   []QquotaLimit
+}
+
+// qquotaFilter is a filter element like <users user="alice"/>.
+type qquotaFilter struct {
+	User    string `xml:"user,attr"`
+	Project string `xml:"project,attr"`
+	PE      string `xml:"pe,attr"`
+	Queue   string `xml:"queue,attr"`
+	Host    string `xml:"host,attr"`
+}
+
+// qquotaXMLRule is the XML representation of a qquota rule where the
+// filter values are attributes.
+type qquotaXMLRule struct {
+	Name     string         `xml:"name,attr"`
+	Users    []qquotaFilter `xml:"users"`
+	Projects []qquotaFilter `xml:"projects"`
+	PEs      []qquotaFilter `xml:"pes"`
+	Queues   []qquotaFilter `xml:"queues"`
+	Hosts    []qquotaFilter `xml:"hosts"`
+	Limits   []QquotaLimit  `xml:"limit"`
+}
+
+// qquotaResult is the representation of the qquota -xml output.
+type qquotaResult struct {
+	XMLName xml.Name        `xml:"qquota_result"`
+	Rules   []qquotaXMLRule `xml:"qquota_rule"`
+}
+
+// parseQquota parses the xml output of qquota -xml.
+func parseQquota(xmlOut []byte) ([]QquotaRule, error) {
+	var qr qquotaResult
+	if err := xml.Unmarshal(xmlOut, &qr); err != nil {
+		return nil, errors.New("XML qquota result unmarshall error")
+	}
+	rules := make([]QquotaRule, 0, len(qr.Rules))
+	for _, r := range qr.Rules {
+		rule := QquotaRule{Name: r.Name, Limits: r.Limits}
+		for _, f := range r.Users {
+			rule.Users = append(rule.Users, f.User)
+		}
+		for _, f := range r.Projects {
+			rule.Projects = append(rule.Projects, f.Project)
+		}
+		for _, f := range r.PEs {
+			rule.PEs = append(rule.PEs, f.PE)
+		}
+		for _, f := range r.Queues {
+			rule.Queues = append(rule.Queues, f.Queue)
+		}
+		for _, f := range r.Hosts {
+			rule.Hosts = append(rule.Hosts, f.Host)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// args returns the qquota command line arguments for the options.
+func (opts QquotaOptions) args() []string {
+	args := []string{"-xml"}
+	add := func(option string, list []string) {
+		if len(list) > 0 {
+			args = append(args, option, strings.Join(list, ","))
+		}
+	}
+	add("-u", opts.Users)
+	add("-P", opts.Projects)
+	add("-q", opts.Queues)
+	add("-h", opts.Hosts)
+	add("-pe", opts.PEs)
+	return args
+}
+
+// Qquota executes qquota -xml with the given filters and returns the
+// resource quota rules with their limits and current usage.
+func Qquota(opts QquotaOptions) ([]QquotaRule, error) {
+	args := opts.args()
+	cmd := exec.Command("qquota", args...)
+	out, errOut := cmd.Output()
+	if errOut != nil {
+		log.Printf("Could not execute qquota %s.", strings.Join(args, " "))
+		return nil, errOut
+	}
+	rules, err := parseQquota(out)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+// QquotaUsage converts the qquota rules into the QuotaUsage entries which are
+// required by EvaluateQuotas. Limits without a usage value are skipped.
+func QquotaUsage(rules []QquotaRule) []QuotaUsage {
+	var usage []QuotaUsage
+	for _, r := range rules {
+		for _, l := range r.Limits {
+			if l.Value == "" {
+				continue
+			}
+			used, err := parseLimit(l.Value)
+			if err != nil {
+				continue
+			}
+			usage = append(usage, QuotaUsage{
+				Rule:     r.Name,
+				Users:    strings.Join(r.Users, ","),
+				Projects: strings.Join(r.Projects, ","),
+				PEs:      strings.Join(r.PEs, ","),
+				Queues:   strings.Join(r.Queues, ","),
+				Hosts:    strings.Join(r.Hosts, ","),
+				Resource: l.Resource,
+				Used:     used,
+			})
+		}
+	}
+	return usage
+}
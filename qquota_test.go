@@ -0,0 +1,75 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"strings"
+	"testing"
+)
+
+var testQquota = `<?xml version='1.0'?>
+<qquota_result xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qquota/qquota.xsd?revision=1.2">
+ <qquota_rule name="max_per_user/1">
+   <users user="alice"/>
+   <queues queue="all.q"/>
+   <limit resource="slots" limit="50" value="40"/>
+ </qquota_rule>
+ <qquota_rule name="bigmem/nobob">
+   <hosts host="host2"/>
+   <limit resource="h_vmem" limit="4G" value="3G"/>
+   <limit resource="arch" limit="lx-amd64"/>
+ </qquota_rule>
+</qquota_result>`
+
+func TestParseQquota(t *testing.T) {
+	rules, err := parseQquota([]byte(testQquota))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules but got %d", len(rules))
+	}
+	if rules[0].Name != "max_per_user/1" {
+		t.Errorf("Wrong rule name: %s", rules[0].Name)
+	}
+	if len(rules[0].Users) != 1 || rules[0].Users[0] != "alice" {
+		t.Errorf("Wrong users: %v", rules[0].Users)
+	}
+	if s := rules[0].Limits[0].String(); s != "slots=40/50" {
+		t.Errorf("Wrong limit: %s", s)
+	}
+	if s := rules[1].Limits[1].String(); s != "arch=lx-amd64" {
+		t.Errorf("Wrong static limit: %s", s)
+	}
+	usage := QquotaUsage(rules)
+	if len(usage) != 2 {
+		t.Fatalf("Expected 2 usage entries but got %d", len(usage))
+	}
+	if usage[0].Rule != "max_per_user/1" || usage[0].Users != "alice" || usage[0].Used != 40 {
+		t.Errorf("Wrong usage: %v", usage[0])
+	}
+	if _, err := parseQquota([]byte("<qquota_result>")); err == nil {
+		t.Error("Expected error for broken XML")
+	}
+}
+
+func TestQquotaOptions(t *testing.T) {
+	opts := QquotaOptions{Users: []string{"alice", "bob"}, PEs: []string{"mpi"}}
+	if args := strings.Join(opts.args(), " "); args != "-xml -u alice,bob -pe mpi" {
+		t.Errorf("Wrong arguments: %s", args)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"fmt"
+	"github.com/dgruber/ugego/pkg/quantity"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseQuantity parses a numeric Grid Engine value like 4G, 1.5k,
+// 64314.000000M or INFINITY and returns it as plain number. Memory
+// units are resolved into bytes (see quantity.Parse).
+func ParseQuantity(value string) (float64, error) {
+	return quantity.Parse(value)
+}
+
+// parseKeyValues parses a Grid Engine list of name=value pairs like
+// "slots=16,h_vmem=64G" into a map. NONE is returned as empty map.
+func parseKeyValues(value string) (map[string]string, error) {
+	kvs := make(map[string]string)
+	for _, kv := range splitList(value) {
+		eq := strings.Index(kv, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("%s is not a name=value pair", kv)
+		}
+		kvs[kv[:eq]] = kv[eq+1:]
+	}
+	return kvs, nil
+}
+
+// ParseTimeValue parses a Grid Engine time value in the format
+// [[hours:]minutes:]seconds like 1:30:00 or 5400.
+func ParseTimeValue(value string) (time.Duration, error) {
+	v := strings.TrimSpace(value)
+	if strings.EqualFold(v, "INFINITY") {
+		return time.Duration(math.MaxInt64), nil
+	}
+	parts := strings.Split(v, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("time value %s has too many fields", value)
+	}
+	var seconds float64
+	for _, p := range parts {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, fmt.Errorf("time value %s is not numeric", value)
+		}
+		seconds = seconds*60 + f
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// FormatTimeValue returns the duration in the Grid Engine time format
+// hours:minutes:seconds. The maximum duration is returned as INFINITY.
+func FormatTimeValue(d time.Duration) string {
+	if d == time.Duration(math.MaxInt64) {
+		return "INFINITY"
+	}
+	seconds := int64(d / time.Second)
+	return fmt.Sprintf("%d:%d:%d", seconds/3600, seconds/60%60, seconds%60)
+}
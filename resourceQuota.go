@@ -0,0 +1,493 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ResourceQuotaSet is a Univa Grid Engine resource quota set as shown
+// by qconf -srqs.
+type ResourceQuotaSet struct {
+	Name        string
+	Description string
+	Enabled     bool
+	Rules       []ResourceQuotaRule
+}
+
+// ResourceQuotaRule is one limit rule of a resource quota set like
+// "limit name r1 users {*} queues all.q to slots=10". Filters which
+// are not specified in the rule match everything.
+type ResourceQuotaRule struct {
+	Name     string
+	Users    QuotaFilter
+	Projects QuotaFilter
+	PEs      QuotaFilter
+	Queues   QuotaFilter
+	Hosts    QuotaFilter
+	Limits   []QuotaLimit
+}
+
+// QuotaFilter is the list of users, projects, pes, queues, or hosts a
+// rule applies to. Entries starting with ! are excluded, entries can be
+// wildcard patterns, ACLs (users), or host groups (hosts). When Expand
+// is set (list in {}) the limit applies to each matching element
+// separately instead of to all of them together.
+type QuotaFilter struct {
+	Expand  bool
+	Entries []string
+}
+
+// QuotaLimit is one resource=value limit of a rule. Value can be a
+// number, a time, or a dynamic expression like $num_proc*2.
+type QuotaLimit struct {
+	Resource string
+	Value    string
+}
+
+// rqsFilterKeywords are the filter keywords of a rule in output order.
+var rqsFilterKeywords = []string{"users", "projects", "pes", "queues", "hosts"}
+
+// filter returns a pointer to the filter of the rule for the keyword.
+func (r *ResourceQuotaRule) filter(keyword string) *QuotaFilter {
+	switch keyword {
+	case "users":
+		return &r.Users
+	case "projects":
+		return &r.Projects
+	case "pes":
+		return &r.PEs
+	case "queues":
+		return &r.Queues
+	case "hosts":
+		return &r.Hosts
+	}
+	return nil
+}
+
+// ParseResourceQuotaSets parses the output of qconf -srqs which can
+// contain multiple resource quota sets, each enclosed in {}.
+func ParseResourceQuotaSets(rqs string) ([]ResourceQuotaSet, error) {
+	var sets []ResourceQuotaSet
+	rest := rqs
+	for {
+		start := strings.Index(rest, "{\n")
+		if start < 0 {
+			start = strings.Index(rest, "{\r\n")
+		}
+		if start < 0 {
+			if strings.TrimSpace(rest) != "" {
+				return nil, fmt.Errorf("unexpected content outside of resource quota set: %s", strings.TrimSpace(rest))
+			}
+			return sets, nil
+		}
+		if strings.TrimSpace(rest[:start]) != "" {
+			return nil, fmt.Errorf("unexpected content outside of resource quota set: %s", strings.TrimSpace(rest[:start]))
+		}
+		end := strings.Index(rest[start:], "\n}")
+		if end < 0 {
+			return nil, errors.New("resource quota set is not terminated by }")
+		}
+		set, err := ParseResourceQuotaSet(rest[start+1 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, *set)
+		rest = rest[start+end+2:]
+	}
+}
+
+// ParseResourceQuotaSet parses the content of one resource quota set
+// (without the surrounding {}).
+func ParseResourceQuotaSet(rqs string) (*ResourceQuotaSet, error) {
+	attrs, err := parseAttributes(rqs)
+	if err != nil {
+		return nil, err
+	}
+	var set ResourceQuotaSet
+	for _, a := range attrs {
+		switch a.name {
+		case "name":
+			set.Name = a.value
+		case "description":
+			if a.value != "NONE" {
+				set.Description = strings.Trim(a.value, "\"")
+			}
+		case "enabled":
+			set.Enabled = strings.EqualFold(a.value, "TRUE")
+		case "limit":
+			rule, err := ParseResourceQuotaRule(a.value)
+			if err != nil {
+				return nil, fmt.Errorf("error in resource quota set %s: %s", set.Name, err)
+			}
+			set.Rules = append(set.Rules, *rule)
+		default:
+			return nil, fmt.Errorf("unknown resource quota set attribute %s", a.name)
+		}
+	}
+	if set.Name == "" {
+		return nil, errors.New("resource quota set has no name")
+	}
+	return &set, nil
+}
+
+// ParseResourceQuotaRule parses the value of a limit line of a
+// resource quota set like "users {*} queues all.q to slots=10".
+func ParseResourceQuotaRule(limit string) (*ResourceQuotaRule, error) {
+	var rule ResourceQuotaRule
+	tokens := strings.Fields(limit)
+	for i := 0; i < len(tokens); {
+		keyword := tokens[i]
+		// collect the value which can contain spaces after commas
+		j := i + 1
+		for j < len(tokens) && !isRuleKeyword(tokens[j]) {
+			j++
+		}
+		value := strings.Join(tokens[i+1:j], "")
+		if value == "" {
+			return nil, fmt.Errorf("keyword %s has no value in rule: %s", keyword, limit)
+		}
+		switch keyword {
+		case "name":
+			rule.Name = value
+		case "to":
+			for _, l := range strings.Split(value, ",") {
+				eq := strings.Index(l, "=")
+				if eq <= 0 {
+					return nil, fmt.Errorf("limit %s is not a resource=value pair", l)
+				}
+				rule.Limits = append(rule.Limits, QuotaLimit{Resource: l[:eq], Value: l[eq+1:]})
+			}
+		default:
+			f := rule.filter(keyword)
+			if f == nil {
+				return nil, fmt.Errorf("unknown keyword %s in rule: %s", keyword, limit)
+			}
+			*f = ParseQuotaFilter(value)
+		}
+		i = j
+	}
+	if len(rule.Limits) == 0 {
+		return nil, fmt.Errorf("rule has no limits: %s", limit)
+	}
+	return &rule, nil
+}
+
+func isRuleKeyword(token string) bool {
+	switch token {
+	case "name", "users", "projects", "pes", "queues", "hosts", "to":
+		return true
+	}
+	return false
+}
+
+// ParseQuotaFilter parses a filter list like "{*}", "!bob,@dept",
+// or "all.q".
+func ParseQuotaFilter(filter string) QuotaFilter {
+	var f QuotaFilter
+	filter = strings.TrimSpace(filter)
+	if strings.HasPrefix(filter, "{") && strings.HasSuffix(filter, "}") {
+		f.Expand = true
+		filter = filter[1 : len(filter)-1]
+	}
+	f.Entries = splitList(filter)
+	return f
+}
+
+// String returns the filter in resource quota set format.
+func (f QuotaFilter) String() string {
+	list := strings.Join(f.Entries, ",")
+	if f.Expand {
+		return "{" + list + "}"
+	}
+	return list
+}
+
+// IsSet returns true if the filter was specified in the rule.
+func (f QuotaFilter) IsSet() bool {
+	return len(f.Entries) > 0
+}
+
+// Match returns true when the value is selected by the filter. The
+// match function decides whether a single (not negated) entry of the
+// filter selects the value. An unset filter matches everything. An
+// empty value is only matched by a filter which consists of excluding
+// entries only.
+func (f QuotaFilter) Match(value string, match func(entry, value string) bool) bool {
+	if !f.IsSet() {
+		return true
+	}
+	positive := false
+	matched := false
+	for _, e := range f.Entries {
+		if strings.HasPrefix(e, "!") {
+			if value != "" && match(e[1:], value) {
+				return false
+			}
+			continue
+		}
+		positive = true
+		if value != "" && match(e, value) {
+			matched = true
+		}
+	}
+	return matched || !positive
+}
+
+// String returns the rule in the format of a limit line.
+func (r ResourceQuotaRule) String() string {
+	var parts []string
+	if r.Name != "" {
+		parts = append(parts, "name", r.Name)
+	}
+	for _, keyword := range rqsFilterKeywords {
+		if f := r.filter(keyword); f.IsSet() {
+			parts = append(parts, keyword, f.String())
+		}
+	}
+	limits := make([]string, 0, len(r.Limits))
+	for _, l := range r.Limits {
+		limits = append(limits, l.Resource+"="+l.Value)
+	}
+	parts = append(parts, "to", strings.Join(limits, ","))
+	return strings.Join(parts, " ")
+}
+
+// String returns the resource quota set in the qconf file format.
+func (s ResourceQuotaSet) String() string {
+	description := "NONE"
+	if s.Description != "" {
+		description = "\"" + s.Description + "\""
+	}
+	enabled := "FALSE"
+	if s.Enabled {
+		enabled = "TRUE"
+	}
+	attrs := []attribute{
+		{"name", s.Name},
+		{"description", description},
+		{"enabled", enabled},
+	}
+	for _, r := range s.Rules {
+		attrs = append(attrs, attribute{"limit", r.String()})
+	}
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for _, line := range strings.SplitAfter(formatAttributes(attrs), "\n") {
+		if line != "" {
+			buf.WriteString("   " + line)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// RuleID returns the name under which Grid Engine reports the rule at
+// the given index (starting with 0), like max_per_user/1 for an unnamed
+// or max_per_user/slots for a named rule.
+func (s ResourceQuotaSet) RuleID(index int) string {
+	if name := s.Rules[index].Name; name != "" {
+		return s.Name + "/" + name
+	}
+	return fmt.Sprintf("%s/%d", s.Name, index+1)
+}
+
+// GetResourceQuotaSetList calls qconf -srqsl and returns the names of
+// all resource quota sets.
+func GetResourceQuotaSetList() ([]string, error) {
+	return qconfList("-srqsl")
+}
+
+// GetResourceQuotaSets calls qconf -srqs for the given resource quota
+// sets (or all when no name is given) and parses the output.
+func GetResourceQuotaSets(names ...string) ([]ResourceQuotaSet, error) {
+	args := []string{"-srqs"}
+	if len(names) > 0 {
+		args = append(args, strings.Join(names, ","))
+	}
+	out, err := qconf(args...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseResourceQuotaSets(string(out))
+}
+
+// QuotaRequest describes a job for which the resource quota sets are
+// evaluated on a particular queue instance. Resources contains the
+// requested amount of each resource, including slots.
+type QuotaRequest struct {
+	User      string
+	Group     string
+	Project   string
+	PE        string
+	Queue     string
+	Host      string
+	Resources map[string]float64
+}
+
+// QuotaUsage is the amount of a resource which is currently consumed
+// within a rule, like it is reported by qquota. Rule is the name of
+// the rule as returned by RuleID. The filters select the instance of
+// an expanded rule (like users=alice for users {*}), empty filters
+// match everything.
+type QuotaUsage struct {
+	Rule     string
+	Users    string
+	Projects string
+	PEs      string
+	Queues   string
+	Hosts    string
+	Resource string
+	Used     float64
+}
+
+// QuotaEnvironment contains the cluster configuration required for
+// evaluating ACL and host group entries of resource quota rules.
+// HostGroups is the output of ResolveHostGroups.
+type QuotaEnvironment struct {
+	HostGroups map[string][]string
+	UserLists  []UserList
+}
+
+// QuotaViolation describes a rule which blocks a job.
+type QuotaViolation struct {
+	Rule      string
+	Resource  string
+	Limit     float64
+	Used      float64
+	Requested float64
+}
+
+// String returns a human readable description of the violation.
+func (v QuotaViolation) String() string {
+	return fmt.Sprintf("rule %s: %s=%g/%g used, %g requested", v.Rule, v.Resource, v.Used, v.Limit, v.Requested)
+}
+
+// EvaluateQuotas checks the job request against the resource quota sets
+// without contacting the cluster and returns all rules which would block
+// the job. Like in Grid Engine only the first matching rule of each
+// enabled resource quota set is taken into account. Limits which are not
+// numeric (like dynamic limits) are not evaluated.
+func EvaluateQuotas(sets []ResourceQuotaSet, req QuotaRequest, usage []QuotaUsage, env QuotaEnvironment) []QuotaViolation {
+	var violations []QuotaViolation
+	for _, set := range sets {
+		if !set.Enabled {
+			continue
+		}
+		for i, rule := range set.Rules {
+			if !env.matchRule(rule.Users, rule.Projects, rule.PEs, rule.Queues, rule.Hosts, req) {
+				continue
+			}
+			id := set.RuleID(i)
+			for _, l := range rule.Limits {
+				requested, exists := req.Resources[l.Resource]
+				if !exists {
+					continue
+				}
+				limit, err := parseLimit(l.Value)
+				if err != nil {
+					continue
+				}
+				used := env.used(usage, id, l.Resource, req)
+				if used+requested > limit {
+					violations = append(violations, QuotaViolation{
+						Rule:      id,
+						Resource:  l.Resource,
+						Limit:     limit,
+						Used:      used,
+						Requested: requested,
+					})
+				}
+			}
+			// only the first matching rule of a set is applied
+			break
+		}
+	}
+	return violations
+}
+
+// used sums up the usage of the resource within the rule instances
+// the job request belongs to.
+func (env QuotaEnvironment) used(usage []QuotaUsage, rule, resource string, req QuotaRequest) float64 {
+	var sum float64
+	for _, u := range usage {
+		if u.Rule != rule || u.Resource != resource {
+			continue
+		}
+		if env.matchRule(ParseQuotaFilter(u.Users), ParseQuotaFilter(u.Projects),
+			ParseQuotaFilter(u.PEs), ParseQuotaFilter(u.Queues), ParseQuotaFilter(u.Hosts), req) {
+			sum += u.Used
+		}
+	}
+	return sum
+}
+
+// matchRule returns true if all filters select the job request.
+func (env QuotaEnvironment) matchRule(users, projects, pes, queues, hosts QuotaFilter, req QuotaRequest) bool {
+	return users.Match(req.User, func(e, v string) bool { return env.matchUser(e, v, req.Group) }) &&
+		projects.Match(req.Project, matchPattern) &&
+		pes.Match(req.PE, matchPattern) &&
+		queues.Match(req.Queue, matchPattern) &&
+		hosts.Match(req.Host, env.matchHost)
+}
+
+// matchUser checks if a user entry (user name, pattern, or @ACL)
+// selects the user.
+func (env QuotaEnvironment) matchUser(entry, user, group string) bool {
+	if !strings.HasPrefix(entry, "@") {
+		return matchPattern(entry, user)
+	}
+	for _, ul := range env.UserLists {
+		if ul.Name == entry[1:] && ul.Contains(user, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost checks if a host entry (host name, pattern, or @hostgroup)
+// selects the host.
+func (env QuotaEnvironment) matchHost(entry, host string) bool {
+	if !IsHostGroup(entry) {
+		return matchPattern(entry, host)
+	}
+	for _, h := range env.HostGroups[entry] {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a value against a wildcard pattern.
+func matchPattern(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// parseLimit parses a numeric or time (hh:mm:ss) limit.
+func parseLimit(value string) (float64, error) {
+	if strings.Contains(value, ":") {
+		d, err := ParseTimeValue(value)
+		return d.Seconds(), err
+	}
+	return ParseQuantity(value)
+}
@@ -0,0 +1,146 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+)
+
+var testRQS = `{
+   name         max_per_user
+   description  "per user slot limit"
+   enabled      TRUE
+   limit        users {*} queues all.q to slots=10
+}
+{
+   name         bigmem
+   description  NONE
+   enabled      TRUE
+   limit        name nobob users !bob hosts {@bigmem} to slots=2, \
+                h_vmem=4G
+   limit        to slots=100
+}
+{
+   name         disabled
+   description  NONE
+   enabled      FALSE
+   limit        to slots=0
+}
+`
+
+func TestParseResourceQuotaSets(t *testing.T) {
+	sets, err := ParseResourceQuotaSets(testRQS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 3 {
+		t.Fatalf("Expected 3 resource quota sets but got %d", len(sets))
+	}
+	if sets[0].Description != "per user slot limit" {
+		t.Errorf("Wrong description: %s", sets[0].Description)
+	}
+	r := sets[0].Rules[0]
+	if !r.Users.Expand || r.Users.Entries[0] != "*" {
+		t.Errorf("Wrong users filter: %v", r.Users)
+	}
+	if r.Queues.Expand || r.Queues.Entries[0] != "all.q" {
+		t.Errorf("Wrong queues filter: %v", r.Queues)
+	}
+	if r.Hosts.IsSet() {
+		t.Errorf("Hosts filter must not be set: %v", r.Hosts)
+	}
+	r = sets[1].Rules[0]
+	if r.Name != "nobob" || len(r.Limits) != 2 || r.Limits[1].Value != "4G" {
+		t.Errorf("Wrong rule: %s", r)
+	}
+	if sets[1].RuleID(0) != "bigmem/nobob" || sets[1].RuleID(1) != "bigmem/2" {
+		t.Errorf("Wrong rule ids: %s %s", sets[1].RuleID(0), sets[1].RuleID(1))
+	}
+	if sets[2].Enabled {
+		t.Error("Resource quota set disabled must not be enabled")
+	}
+	// round trip
+	var out string
+	for _, s := range sets {
+		out += s.String()
+	}
+	again, err := ParseResourceQuotaSets(out)
+	if err != nil {
+		t.Fatalf("Round trip failed: %s\n%s", err, out)
+	}
+	for i := range sets {
+		if again[i].String() != sets[i].String() {
+			t.Errorf("Round trip failed:\n%s\n%s", sets[i], again[i])
+		}
+	}
+	if _, err := ParseResourceQuotaSets("{\n name x\n limit users a\n}"); err == nil {
+		t.Error("Expected error for rule without limits")
+	}
+}
+
+func TestEvaluateQuotas(t *testing.T) {
+	sets, err := ParseResourceQuotaSets(testRQS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := QuotaEnvironment{HostGroups: map[string][]string{"@bigmem": {"host2"}}}
+	usage := []QuotaUsage{
+		{Rule: "max_per_user/1", Users: "alice", Queues: "all.q", Resource: "slots", Used: 8},
+		{Rule: "max_per_user/1", Users: "carol", Queues: "all.q", Resource: "slots", Used: 10},
+		{Rule: "bigmem/nobob", Users: "alice", Hosts: "host2", Resource: "h_vmem", Used: 3 * 1024 * 1024 * 1024},
+	}
+	req := QuotaRequest{User: "alice", Queue: "all.q", Host: "host1",
+		Resources: map[string]float64{"slots": 2}}
+	if v := EvaluateQuotas(sets, req, usage, env); len(v) != 0 {
+		t.Errorf("Expected no violation but got %v", v)
+	}
+	req.Resources["slots"] = 3
+	v := EvaluateQuotas(sets, req, usage, env)
+	if len(v) != 1 || v[0].Rule != "max_per_user/1" || v[0].Used != 8 || v[0].Limit != 10 {
+		t.Errorf("Expected violation of max_per_user/1 but got %v", v)
+	}
+	// first matching rule of bigmem is nobob on host2
+	req = QuotaRequest{User: "alice", Queue: "other.q", Host: "host2",
+		Resources: map[string]float64{"slots": 1, "h_vmem": 2 * 1024 * 1024 * 1024}}
+	v = EvaluateQuotas(sets, req, usage, env)
+	if len(v) != 1 || v[0].Rule != "bigmem/nobob" || v[0].Resource != "h_vmem" {
+		t.Errorf("Expected violation of bigmem/nobob but got %v", v)
+	}
+	// bob is excluded from nobob, hence the second rule applies
+	req.User = "bob"
+	if v = EvaluateQuotas(sets, req, usage, env); len(v) != 0 {
+		t.Errorf("Expected no violation for bob but got %v", v)
+	}
+}
+
+func TestQuotaFilterMatch(t *testing.T) {
+	env := QuotaEnvironment{UserLists: []UserList{{Name: "dept", Entries: []string{"alice", "%staff"}}}}
+	match := func(e, v string) bool { return env.matchUser(e, v, "") }
+	f := ParseQuotaFilter("@dept,!alice")
+	if f.Match("alice", match) {
+		t.Error("alice must be excluded")
+	}
+	if !env.matchUser("@dept", "dave", "staff") {
+		t.Error("dave must be member of dept by its group staff")
+	}
+	if ParseQuotaFilter("a*").Match("", matchPattern) {
+		t.Error("Empty value must not match a positive filter")
+	}
+	if !ParseQuotaFilter("!b").Match("a", matchPattern) {
+		t.Error("a must be matched by !b")
+	}
+}
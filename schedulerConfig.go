@@ -0,0 +1,140 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"time"
+)
+
+// SchedulerConfig is the Univa Grid Engine scheduler configuration as
+// shown by qconf -ssconf. Time values are decoded into durations,
+// weights into numbers. Attributes which are not part of the struct
+// are kept in Other so that they are not lost when writing the
+// configuration back.
+type SchedulerConfig struct {
+	Algorithm                   string
+	ScheduleInterval            time.Duration
+	MaxUJobs                    int
+	QueueSortMethod             string
+	JobLoadAdjustments          map[string]float64
+	LoadAdjustmentDecayTime     time.Duration
+	LoadFormula                 string
+	ScheddJobInfo               string
+	FlushSubmitSec              int
+	FlushFinishSec              int
+	Params                      map[string]string
+	ReprioritizeInterval        time.Duration
+	Halftime                    int // hours
+	UsageWeightList             map[string]float64
+	CompensationFactor          float64
+	WeightUser                  float64
+	WeightProject               float64
+	WeightDepartment            float64
+	WeightJob                   float64
+	WeightTicketsFunctional     int
+	WeightTicketsShare          int
+	ShareOverrideTickets        bool
+	ShareFunctionalShares       bool
+	MaxFunctionalJobsToSchedule int
+	ReportPJobTickets           bool
+	MaxPendingTasksPerJob       int
+	HalflifeDecayList           string
+	PolicyHierarchy             string
+	WeightTicket                float64
+	WeightWaitingTime           float64
+	WeightDeadline              float64
+	WeightUrgency               float64
+	WeightPriority              float64
+	MaxReservation              int
+	DefaultDuration             time.Duration
+	Other                       map[string]string
+}
+
+// fields binds the attribute names of qconf -ssconf to the struct fields.
+func (sc *SchedulerConfig) fields() []configField {
+	return []configField{
+		{"algorithm", &sc.Algorithm},
+		{"schedule_interval", &sc.ScheduleInterval},
+		{"maxujobs", &sc.MaxUJobs},
+		{"queue_sort_method", &sc.QueueSortMethod},
+		{"job_load_adjustments", &sc.JobLoadAdjustments},
+		{"load_adjustment_decay_time", &sc.LoadAdjustmentDecayTime},
+		{"load_formula", &sc.LoadFormula},
+		{"schedd_job_info", &sc.ScheddJobInfo},
+		{"flush_submit_sec", &sc.FlushSubmitSec},
+		{"flush_finish_sec", &sc.FlushFinishSec},
+		{"params", &sc.Params},
+		{"reprioritize_interval", &sc.ReprioritizeInterval},
+		{"halftime", &sc.Halftime},
+		{"usage_weight_list", &sc.UsageWeightList},
+		{"compensation_factor", &sc.CompensationFactor},
+		{"weight_user", &sc.WeightUser},
+		{"weight_project", &sc.WeightProject},
+		{"weight_department", &sc.WeightDepartment},
+		{"weight_job", &sc.WeightJob},
+		{"weight_tickets_functional", &sc.WeightTicketsFunctional},
+		{"weight_tickets_share", &sc.WeightTicketsShare},
+		{"share_override_tickets", &sc.ShareOverrideTickets},
+		{"share_functional_shares", &sc.ShareFunctionalShares},
+		{"max_functional_jobs_to_schedule", &sc.MaxFunctionalJobsToSchedule},
+		{"report_pjob_tickets", &sc.ReportPJobTickets},
+		{"max_pending_tasks_per_job", &sc.MaxPendingTasksPerJob},
+		{"halflife_decay_list", &sc.HalflifeDecayList},
+		{"policy_hierarchy", &sc.PolicyHierarchy},
+		{"weight_ticket", &sc.WeightTicket},
+		{"weight_waiting_time", &sc.WeightWaitingTime},
+		{"weight_deadline", &sc.WeightDeadline},
+		{"weight_urgency", &sc.WeightUrgency},
+		{"weight_priority", &sc.WeightPriority},
+		{"max_reservation", &sc.MaxReservation},
+		{"default_duration", &sc.DefaultDuration},
+	}
+}
+
+// ParseSchedulerConfig parses the scheduler configuration in the format
+// of qconf -ssconf.
+func ParseSchedulerConfig(sconf string) (*SchedulerConfig, error) {
+	attrs, err := parseAttributes(sconf)
+	if err != nil {
+		return nil, err
+	}
+	var sc SchedulerConfig
+	if sc.Other, err = decodeFields(attrs, sc.fields()); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// String returns the scheduler configuration in the qconf file format.
+func (sc SchedulerConfig) String() string {
+	return formatAttributes(encodeFields(sc.fields(), sc.Other))
+}
+
+// GetSchedulerConfig calls qconf -ssconf and parses the output.
+func GetSchedulerConfig() (*SchedulerConfig, error) {
+	out, err := qconf("-ssconf")
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchedulerConfig(string(out))
+}
+
+// ModifySchedulerConfig replaces the scheduler configuration of the
+// cluster (qconf -Msconf).
+func ModifySchedulerConfig(sc SchedulerConfig) error {
+	return qconfFromFile("-Msconf", sc.String())
+}
@@ -0,0 +1,104 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var testSchedulerConfig = `algorithm                         default
+schedule_interval                 0:0:15
+maxujobs                          0
+queue_sort_method                 load
+job_load_adjustments              np_load_avg=0.50
+load_adjustment_decay_time        0:7:30
+load_formula                      np_load_avg
+schedd_job_info                   false
+flush_submit_sec                  0
+flush_finish_sec                  0
+params                            MONITOR=1,PROFILE=true
+reprioritize_interval             0:0:0
+halftime                          168
+usage_weight_list                 cpu=1.000000,mem=0.000000,io=0.000000
+compensation_factor               5.000000
+weight_user                       0.250000
+weight_project                    0.250000
+weight_department                 0.250000
+weight_job                        0.250000
+weight_tickets_functional         0
+weight_tickets_share              0
+share_override_tickets            TRUE
+share_functional_shares           TRUE
+max_functional_jobs_to_schedule   200
+report_pjob_tickets               TRUE
+max_pending_tasks_per_job         50
+halflife_decay_list               none
+policy_hierarchy                  OFS
+weight_ticket                     0.010000
+weight_waiting_time               0.000000
+weight_deadline                   3600000.000000
+weight_urgency                    0.100000
+weight_priority                   1.000000
+max_reservation                   0
+default_duration                  INFINITY
+fair_urgency_list                 NONE`
+
+func TestParseSchedulerConfig(t *testing.T) {
+	sc, err := ParseSchedulerConfig(testSchedulerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.ScheduleInterval != 15*time.Second {
+		t.Errorf("Wrong schedule interval: %s", sc.ScheduleInterval)
+	}
+	if sc.LoadAdjustmentDecayTime != 7*time.Minute+30*time.Second {
+		t.Errorf("Wrong load adjustment decay time: %s", sc.LoadAdjustmentDecayTime)
+	}
+	if sc.DefaultDuration != time.Duration(math.MaxInt64) {
+		t.Errorf("Default duration is not INFINITY: %s", sc.DefaultDuration)
+	}
+	if sc.JobLoadAdjustments["np_load_avg"] != 0.5 {
+		t.Errorf("Wrong job load adjustments: %v", sc.JobLoadAdjustments)
+	}
+	if sc.UsageWeightList["cpu"] != 1 {
+		t.Errorf("Wrong usage weight list: %v", sc.UsageWeightList)
+	}
+	if sc.Params["MONITOR"] != "1" || sc.Params["PROFILE"] != "true" {
+		t.Errorf("Wrong params: %v", sc.Params)
+	}
+	if sc.Halftime != 168 || sc.MaxFunctionalJobsToSchedule != 200 || !sc.ShareOverrideTickets {
+		t.Errorf("Wrong values: %v", sc)
+	}
+	if sc.WeightUrgency != 0.1 || sc.WeightDeadline != 3600000 {
+		t.Errorf("Wrong weights: %f %f", sc.WeightUrgency, sc.WeightDeadline)
+	}
+	if sc.Other["fair_urgency_list"] != "NONE" {
+		t.Errorf("Unknown attribute is not kept: %v", sc.Other)
+	}
+	again, err := ParseSchedulerConfig(sc.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != sc.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", sc, again)
+	}
+	if _, err := ParseSchedulerConfig("schedule_interval abc"); err == nil {
+		t.Error("Expected error for wrong schedule interval")
+	}
+}
@@ -0,0 +1,364 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShareTreeNodeType determines if a share tree node is a user (or
+// department, or default) node or a project node.
+type ShareTreeNodeType int
+
+const (
+	// ShareTreeUserNode is a user, department, or default node
+	ShareTreeUserNode ShareTreeNodeType = iota
+	// ShareTreeProjectNode is a project node
+	ShareTreeProjectNode
+)
+
+// ShareTreeNode is a node of the Univa Grid Engine share tree as shown
+// by qconf -sstree. The root node has no Parent.
+type ShareTreeNode struct {
+	ID       int
+	Name     string
+	Type     ShareTreeNodeType
+	Shares   int
+	Parent   *ShareTreeNode
+	Children []*ShareTreeNode
+}
+
+// ParseShareTree parses the share tree in the format of qconf -sstree
+// and returns the root node:
+// id=0
+// name=Root
+// type=0
+// shares=1
+// childnodes=1
+// id=1
+// ...
+func ParseShareTree(tree string) (*ShareTreeNode, error) {
+	nodes := make(map[int]*ShareTreeNode)
+	childIDs := make(map[int][]int)
+	var order []int
+	var current *ShareTreeNode
+	for _, line := range strings.Split(tree, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("share tree line %s is not a name=value pair", line)
+		}
+		name, value := line[:eq], line[eq+1:]
+		if name == "id" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("share tree node id %s is not numeric", value)
+			}
+			if _, exists := nodes[id]; exists {
+				return nil, fmt.Errorf("share tree node id %d is not unique", id)
+			}
+			current = &ShareTreeNode{ID: id}
+			nodes[id] = current
+			order = append(order, id)
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("share tree attribute %s before first id", name)
+		}
+		var err error
+		switch name {
+		case "name":
+			current.Name = value
+		case "type":
+			var t int
+			t, err = strconv.Atoi(value)
+			current.Type = ShareTreeNodeType(t)
+		case "shares":
+			current.Shares, err = strconv.Atoi(value)
+		case "childnodes":
+			for _, c := range splitList(value) {
+				var id int
+				if id, err = strconv.Atoi(c); err != nil {
+					break
+				}
+				childIDs[current.ID] = append(childIDs[current.ID], id)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error during %s parsing of share tree node %d: %s", name, current.ID, err)
+		}
+	}
+	if len(order) == 0 {
+		return nil, errors.New("share tree has no nodes")
+	}
+	for _, id := range order {
+		parent := nodes[id]
+		for _, c := range childIDs[id] {
+			child, exists := nodes[c]
+			if !exists {
+				return nil, fmt.Errorf("share tree node %d references unknown child %d", id, c)
+			}
+			if child.Parent != nil || child == parent {
+				return nil, fmt.Errorf("share tree node %d has more than one parent", c)
+			}
+			child.Parent = parent
+			parent.Children = append(parent.Children, child)
+		}
+	}
+	root := nodes[order[0]]
+	if root.Parent != nil {
+		return nil, fmt.Errorf("share tree root node %d has a parent", root.ID)
+	}
+	// all nodes must be reachable from the root, this excludes cycles
+	reachable := 0
+	root.Walk(func(*ShareTreeNode) { reachable++ })
+	if reachable != len(nodes) {
+		return nil, fmt.Errorf("share tree has %d nodes which are not reachable from the root", len(nodes)-reachable)
+	}
+	return root, nil
+}
+
+// Walk calls fn for the node and all its descendants in depth first order.
+func (n *ShareTreeNode) Walk(fn func(*ShareTreeNode)) {
+	fn(n)
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Path returns the path of the node in the tree like /project1/alice
+// as it is used by sge_share_mon. The root node has the path /.
+func (n *ShareTreeNode) Path() string {
+	if n.Parent == nil {
+		return "/"
+	}
+	var names []string
+	for node := n; node.Parent != nil; node = node.Parent {
+		names = append([]string{node.Name}, names...)
+	}
+	return "/" + strings.Join(names, "/")
+}
+
+// Find returns the node with the given path or nil if there is none.
+func (n *ShareTreeNode) Find(path string) *ShareTreeNode {
+	node := n
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		var next *ShareTreeNode
+		for _, c := range node.Children {
+			if c.Name == name {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// FindAll returns all nodes with the given name, like all nodes of a
+// user which appears below different projects.
+func (n *ShareTreeNode) FindAll(name string) []*ShareTreeNode {
+	var found []*ShareTreeNode
+	n.Walk(func(node *ShareTreeNode) {
+		if node.Name == name {
+			found = append(found, node)
+		}
+	})
+	return found
+}
+
+// String returns the share tree below the node in the qconf file format.
+func (n *ShareTreeNode) String() string {
+	var buf bytes.Buffer
+	n.Walk(func(node *ShareTreeNode) {
+		children := make([]string, 0, len(node.Children))
+		for _, c := range node.Children {
+			children = append(children, strconv.Itoa(c.ID))
+		}
+		fmt.Fprintf(&buf, "id=%d\nname=%s\ntype=%d\nshares=%d\nchildnodes=%s\n",
+			node.ID, node.Name, node.Type, node.Shares, joinList(children))
+	})
+	return buf.String()
+}
+
+// GetShareTree calls qconf -sstree and parses the output.
+func GetShareTree() (*ShareTreeNode, error) {
+	out, err := qconf("-sstree")
+	if err != nil {
+		return nil, err
+	}
+	return ParseShareTree(string(out))
+}
+
+// ShareUsage is the fair share standing of a share tree node as it
+// is reported by sge_share_mon.
+type ShareUsage struct {
+	Time             time.Time
+	UsageTime        time.Time
+	NodeName         string
+	UserName         string
+	ProjectName      string
+	Shares           int
+	JobCount         int
+	LevelPercent     float64
+	TotalPercent     float64
+	LongTargetShare  float64
+	ShortTargetShare float64
+	ActualShare      float64
+	Usage            float64
+	CPU              float64
+	Mem              float64
+	IO               float64
+	LTCPU            float64
+	LTMem            float64
+	LTIO             float64
+	LTUsage          float64
+}
+
+// ParseShareMon parses the output of sge_share_mon -n where each line
+// consists of tab separated name=value pairs of one share tree node.
+func ParseShareMon(out string) ([]ShareUsage, error) {
+	var usages []ShareUsage
+	for i, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var u ShareUsage
+		for _, field := range strings.Split(strings.TrimSpace(line), "\t") {
+			eq := strings.Index(field, "=")
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: %s is not a name=value pair", i+1, field)
+			}
+			name, value := strings.TrimSpace(field[:eq]), strings.TrimSpace(field[eq+1:])
+			if err := u.set(name, value); err != nil {
+				return nil, fmt.Errorf("line %d: error during %s parsing: %s", i+1, name, err)
+			}
+		}
+		usages = append(usages, u)
+	}
+	return usages, nil
+}
+
+// set sets the field of the share usage which belongs to the
+// sge_share_mon column name. Unknown columns are ignored.
+func (u *ShareUsage) set(name, value string) error {
+	var err error
+	var seconds int64
+	floats := map[string]*float64{
+		"level%":             &u.LevelPercent,
+		"total%":             &u.TotalPercent,
+		"long_target_share":  &u.LongTargetShare,
+		"short_target_share": &u.ShortTargetShare,
+		"actual_share":       &u.ActualShare,
+		"usage":              &u.Usage,
+		"cpu":                &u.CPU,
+		"mem":                &u.Mem,
+		"io":                 &u.IO,
+		"ltcpu":              &u.LTCPU,
+		"ltmem":              &u.LTMem,
+		"ltio":               &u.LTIO,
+		"ltusage":            &u.LTUsage,
+	}
+	if f, exists := floats[name]; exists {
+		*f, err = strconv.ParseFloat(value, 64)
+		return err
+	}
+	switch name {
+	case "curr_time":
+		seconds, err = strconv.ParseInt(value, 10, 64)
+		u.Time = time.Unix(seconds, 0)
+	case "usage_time":
+		seconds, err = strconv.ParseInt(value, 10, 64)
+		u.UsageTime = time.Unix(seconds, 0)
+	case "node_name":
+		u.NodeName = value
+	case "user_name":
+		u.UserName = value
+	case "project_name":
+		u.ProjectName = value
+	case "shares":
+		u.Shares, err = strconv.Atoi(value)
+	case "job_count":
+		u.JobCount, err = strconv.Atoi(value)
+	}
+	return err
+}
+
+// GetShareUsage executes sge_share_mon once and returns the fair share
+// standing of all share tree nodes.
+func GetShareUsage() ([]ShareUsage, error) {
+	rootPath := os.Getenv("SGE_ROOT")
+	if rootPath == "" {
+		return nil, errors.New("$SGE_ROOT environment variable not set")
+	}
+	shareMon := fmt.Sprintf("%s/utilbin/lx-amd64/sge_share_mon", rootPath)
+	out, err := exec.Command(shareMon, "-c", "1", "-n").Output()
+	if err != nil {
+		log.Printf("Could not execute %s -c 1 -n.", shareMon)
+		return nil, err
+	}
+	return ParseShareMon(string(out))
+}
+
+// Departments returns the departments (user lists of type DEPT) the
+// user is member of, sorted by name. Users who are in no department
+// belong to the defaultdepartment in Grid Engine.
+func Departments(user string, userLists []UserList) []string {
+	var depts []string
+	for _, ul := range userLists {
+		if !isDepartment(ul) {
+			continue
+		}
+		for _, e := range ul.Entries {
+			if e == user {
+				depts = append(depts, ul.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(depts)
+	return depts
+}
+
+// isDepartment returns true if the user list is of type DEPT. The type
+// can be a combination like "ACL DEPT".
+func isDepartment(ul UserList) bool {
+	for _, t := range splitList(ul.Type) {
+		if t == "DEPT" {
+			return true
+		}
+	}
+	return false
+}
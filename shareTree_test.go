@@ -0,0 +1,118 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+)
+
+var testShareTree = `id=0
+name=Root
+type=0
+shares=1
+childnodes=1,2
+id=1
+name=project1
+type=1
+shares=100
+childnodes=3
+id=2
+name=default
+type=0
+shares=10
+childnodes=NONE
+id=3
+name=alice
+type=0
+shares=50
+childnodes=NONE
+`
+
+func TestParseShareTree(t *testing.T) {
+	root, err := ParseShareTree(testShareTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Name != "Root" || len(root.Children) != 2 {
+		t.Fatalf("Wrong root node: %v", root)
+	}
+	alice := root.Find("/project1/alice")
+	if alice == nil {
+		t.Fatal("Could not find /project1/alice")
+	}
+	if alice.Shares != 50 || alice.Parent.Type != ShareTreeProjectNode {
+		t.Errorf("Wrong node alice: %v", alice)
+	}
+	if alice.Path() != "/project1/alice" {
+		t.Errorf("Wrong path: %s", alice.Path())
+	}
+	if len(root.FindAll("alice")) != 1 {
+		t.Error("Expected to find alice once")
+	}
+	if root.Find("/project1/bob") != nil {
+		t.Error("bob must not be found")
+	}
+	again, err := ParseShareTree(root.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != root.String() {
+		t.Errorf("Round trip failed:\n%s\n%s", root, again)
+	}
+	if _, err := ParseShareTree("id=0\nname=Root\nchildnodes=1\nid=1\nname=a\nchildnodes=0"); err == nil {
+		t.Error("Expected error for cyclic share tree")
+	}
+	if _, err := ParseShareTree("id=0\nname=Root\nchildnodes=5"); err == nil {
+		t.Error("Expected error for unknown child node")
+	}
+}
+
+func TestParseShareMon(t *testing.T) {
+	out := "curr_time=1448376371\tusage_time=1448376370\tnode_name=/project1/alice\tuser_name=alice\tproject_name=project1\tshares=50\tjob_count=3\tlevel%=100.00\ttotal%=90.91\tlong_target_share=0.909091\tshort_target_share=0.909091\tactual_share=0.250000\tusage=1234.500000\tcpu=1000.000000\tmem=200.000000\tio=34.500000\tltcpu=5000.000000\tltmem=1000.000000\tltio=100.000000\n"
+	usages, err := ParseShareMon(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 1 {
+		t.Fatalf("Expected 1 share usage but got %d", len(usages))
+	}
+	u := usages[0]
+	if u.NodeName != "/project1/alice" || u.UserName != "alice" || u.ProjectName != "project1" {
+		t.Errorf("Wrong node: %v", u)
+	}
+	if u.ActualShare != 0.25 || u.LongTargetShare != 0.909091 || u.Usage != 1234.5 || u.JobCount != 3 {
+		t.Errorf("Wrong values: %v", u)
+	}
+	if u.Time.Unix() != 1448376371 {
+		t.Errorf("Wrong time: %s", u.Time)
+	}
+	if _, err := ParseShareMon("usage=abc"); err == nil {
+		t.Error("Expected error for non numeric usage")
+	}
+}
+
+func TestDepartments(t *testing.T) {
+	uls := []UserList{
+		{Name: "dept1", Type: "DEPT", Entries: []string{"alice"}},
+		{Name: "acl1", Type: "ACL", Entries: []string{"alice"}},
+		{Name: "dept2", Type: "ACL DEPT", Entries: []string{"bob", "alice"}},
+	}
+	depts := Departments("alice", uls)
+	if len(depts) != 2 || depts[0] != "dept1" || depts[1] != "dept2" {
+		t.Errorf("Wrong departments: %v", depts)
+	}
+}
@@ -0,0 +1,379 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of configuration objects in a Snapshot. Each kind is stored in
+// a directory with the same name.
+const (
+	KindComplexes   = "complexes"
+	KindUserLists   = "userlists"
+	KindProjects    = "projects"
+	KindUsers       = "users"
+	KindCalendars   = "calendars"
+	KindCheckpoints = "ckpts"
+	KindPEs         = "pes"
+	KindExecHosts   = "exechosts"
+	KindHostGroups  = "hostgroups"
+	KindConfig      = "config"
+	KindScheduler   = "scheduler"
+	KindQueues      = "queues"
+	KindRQS         = "rqs"
+	KindShareTree   = "sharetree"
+)
+
+// GlobalObject is the object name of the kinds which exist only once
+// in the cluster (complexes, scheduler, sharetree).
+const GlobalObject = "global"
+
+// objectKind describes how objects of a kind are read and written
+// with qconf.
+type objectKind struct {
+	name string
+	// list is the qconf option which lists the object names, it is
+	// empty for kinds with only one (global) object
+	list string
+	// global is true when a global object exists in addition to the
+	// listed ones (like the global exec host)
+	global bool
+	show   string
+	add    string
+	modify string
+	remove string
+	// normalize removes volatile parts of the qconf output
+	normalize func(string) (string, error)
+}
+
+// objectKinds are all kinds of a snapshot in the order in which they
+// are restored, so that referenced objects exist before they are used.
+var objectKinds = []objectKind{
+	{name: KindComplexes, show: "-sc", modify: "-Mc"},
+	{name: KindUserLists, list: "-sul", show: "-su", add: "-Au", modify: "-Mu", remove: "-dul"},
+	{name: KindProjects, list: "-sprjl", show: "-sprj", add: "-Aprj", modify: "-Mprj", remove: "-dprj"},
+	{name: KindUsers, list: "-suserl", show: "-suser", add: "-Auser", modify: "-Muser", remove: "-duser"},
+	{name: KindCalendars, list: "-scall", show: "-scal", add: "-Acal", modify: "-Mcal", remove: "-dcal"},
+	{name: KindCheckpoints, list: "-sckptl", show: "-sckpt", add: "-Ackpt", modify: "-Mckpt", remove: "-dckpt"},
+	{name: KindPEs, list: "-spl", show: "-sp", add: "-Ap", modify: "-Mp", remove: "-dp"},
+	{name: KindExecHosts, list: "-sel", global: true, show: "-se", add: "-Ae", modify: "-Me", remove: "-de",
+		normalize: normalizeExecHost},
+	{name: KindHostGroups, list: "-shgrpl", show: "-shgrp", add: "-Ahgrp", modify: "-Mhgrp", remove: "-dhgrp"},
+	{name: KindConfig, list: "-sconfl", global: true, show: "-sconf", add: "-Aconf", modify: "-Mconf", remove: "-dconf",
+		normalize: normalizeAttributes},
+	{name: KindScheduler, show: "-ssconf", modify: "-Msconf"},
+	{name: KindQueues, list: "-sql", show: "-sq", add: "-Aq", modify: "-Mq", remove: "-dq"},
+	{name: KindRQS, list: "-srqsl", show: "-srqs", add: "-Arqs", modify: "-Mrqs", remove: "-drqs"},
+	{name: KindShareTree, show: "-sstree", add: "-Astree", modify: "-Mstree", remove: "-dstree"},
+}
+
+// normalizeAttributes rewrites a configuration object in a uniform
+// layout without comments.
+func normalizeAttributes(object string) (string, error) {
+	attrs, err := parseAttributes(object)
+	if err != nil {
+		return "", err
+	}
+	return formatAttributes(attrs), nil
+}
+
+// normalizeExecHost removes the load values and processors which are
+// reported by the execution daemon and can't be configured.
+func normalizeExecHost(object string) (string, error) {
+	attrs, err := parseAttributes(object)
+	if err != nil {
+		return "", err
+	}
+	configured := make([]attribute, 0, len(attrs))
+	for _, a := range attrs {
+		if a.name != "load_values" && a.name != "processors" {
+			configured = append(configured, a)
+		}
+	}
+	return formatAttributes(configured), nil
+}
+
+// Snapshot contains configuration objects in qconf file format indexed
+// by kind (like KindQueues) and object name.
+type Snapshot map[string]map[string]string
+
+// Kinds returns the kinds of the snapshot in restore order.
+func (s Snapshot) Kinds() []string {
+	var kinds []string
+	for _, k := range objectKinds {
+		if _, exists := s[k.name]; exists {
+			kinds = append(kinds, k.name)
+		}
+	}
+	return kinds
+}
+
+// Names returns the sorted object names of a kind.
+func (s Snapshot) Names(kind string) []string {
+	names := make([]string, 0, len(s[kind]))
+	for name := range s[kind] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set adds or replaces an object of the snapshot.
+func (s Snapshot) Set(kind, name, object string) {
+	if s[kind] == nil {
+		s[kind] = make(map[string]string)
+	}
+	s[kind][name] = object
+}
+
+// TakeSnapshot reads all configuration objects from the cluster.
+func TakeSnapshot() (Snapshot, error) {
+	s := make(Snapshot)
+	for _, k := range objectKinds {
+		names := []string{GlobalObject}
+		if k.list != "" {
+			listed, err := qconfList(k.list)
+			if err != nil {
+				return nil, err
+			}
+			if !k.global {
+				names = nil
+			}
+			names = append(names, listed...)
+		}
+		s[k.name] = make(map[string]string)
+		for _, name := range names {
+			args := []string{k.show}
+			if k.list != "" {
+				args = append(args, name)
+			}
+			out, err := qconf(args...)
+			if notDefined(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			object := string(out)
+			if k.normalize != nil {
+				if object, err = k.normalize(object); err != nil {
+					return nil, fmt.Errorf("%s %s: %s", k.name, name, err)
+				}
+			}
+			s[k.name][name] = object
+		}
+	}
+	return s, nil
+}
+
+// Save writes the snapshot into the directory. Each object is stored
+// in qconf file format in <dir>/<kind>/<name>.
+func (s Snapshot) Save(dir string) error {
+	for kind, objects := range s {
+		kindDir := filepath.Join(dir, kind)
+		if err := os.MkdirAll(kindDir, 0755); err != nil {
+			return err
+		}
+		for name, object := range objects {
+			if strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+				return fmt.Errorf("%s name %s can't be used as file name", kind, name)
+			}
+			if err := ioutil.WriteFile(filepath.Join(kindDir, name), []byte(object), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot which was written by Save or Backup.
+// Directories which are not a known kind are ignored.
+func LoadSnapshot(dir string) (Snapshot, error) {
+	s := make(Snapshot)
+	for _, k := range objectKinds {
+		files, err := ioutil.ReadDir(filepath.Join(dir, k.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s[k.name] = make(map[string]string)
+		for _, f := range files {
+			if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			content, err := ioutil.ReadFile(filepath.Join(dir, k.name, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			s[k.name][f.Name()] = string(content)
+		}
+	}
+	return s, nil
+}
+
+// Backup dumps all configuration objects of the cluster into the
+// directory (see Snapshot.Save).
+func Backup(dir string) error {
+	s, err := TakeSnapshot()
+	if err != nil {
+		return err
+	}
+	return s.Save(dir)
+}
+
+// RestoreAction is a qconf call which is executed by Restore.
+type RestoreAction struct {
+	Kind string
+	Name string
+	Args []string
+}
+
+// String returns the qconf command line of the action.
+func (a RestoreAction) String() string {
+	return "qconf " + strings.Join(a.Args, " ")
+}
+
+// Restore re-creates the configuration objects of a backup directory in
+// the cluster. Objects which exist are modified, all others are added.
+// The kinds are restored in dependency order (like user lists before
+// projects and host groups before queues). With dryRun set the actions
+// are only returned but not executed. The dry run still queries the
+// cluster (the qconf list commands) to decide whether an object is added
+// or modified, hence it requires a reachable qmaster. Otherwise the
+// actions executed so far are returned together with the first error.
+func Restore(dir string, dryRun bool) ([]RestoreAction, error) {
+	s, err := LoadSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	var actions []RestoreAction
+	for _, k := range objectKinds {
+		if len(s[k.name]) == 0 {
+			continue
+		}
+		existing, err := existingObjects(k)
+		if err != nil {
+			return nil, err
+		}
+		names := s.Names(k.name)
+		if k.name == KindHostGroups {
+			if names, err = hostGroupOrder(s[k.name]); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range names {
+			option := k.modify
+			if !existing[name] {
+				option = k.add
+			}
+			if option == "" {
+				return actions, fmt.Errorf("%s %s can't be created", k.name, name)
+			}
+			action := RestoreAction{Kind: k.name, Name: name,
+				Args: []string{option, filepath.Join(dir, k.name, name)}}
+			if !dryRun {
+				if _, err := qconf(action.Args...); err != nil {
+					return actions, err
+				}
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// existingObjects returns the names of the objects of the kind which
+// exist in the cluster.
+func existingObjects(k objectKind) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	switch {
+	case k.list != "":
+		names, err := qconfList(k.list)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			existing[name] = true
+		}
+		if k.global {
+			existing[GlobalObject] = true
+		}
+	case k.add == "":
+		existing[GlobalObject] = true
+	default:
+		_, err := qconf(k.show)
+		if err != nil && !notDefined(err) {
+			return nil, err
+		}
+		existing[GlobalObject] = err == nil
+	}
+	return existing, nil
+}
+
+// hostGroupOrder sorts the host groups so that nested host groups are
+// created before the host groups which reference them.
+func hostGroupOrder(objects map[string]string) ([]string, error) {
+	groups := make(map[string]*HostGroup, len(objects))
+	for name, object := range objects {
+		hg, err := ParseHostGroup(object)
+		if err != nil {
+			return nil, fmt.Errorf("host group %s: %s", name, err)
+		}
+		groups[name] = hg
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var order []string
+	state := make(map[string]int) // 1 in progress, 2 done
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("host group cycle detected at %s", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, h := range groups[name].Hostlist {
+			if _, exists := groups[h]; exists && IsHostGroup(h) {
+				if err := visit(h); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
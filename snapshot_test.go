@@ -0,0 +1,155 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeCluster replaces qconf by a function which answers with the
+// outputs stored for the command line and records all calls. Unknown
+// command lines fail like qconf does for objects which are not defined.
+func fakeCluster(outputs map[string]string) (calls *[]string, restore func()) {
+	calls = &[]string{}
+	orig := qconf
+	qconf = func(args ...string) ([]byte, error) {
+		cmd := strings.Join(args, " ")
+		*calls = append(*calls, cmd)
+		if out, exists := outputs[cmd]; exists {
+			return []byte(out), nil
+		}
+		return nil, &QconfError{Args: args, Message: "no object defined", Err: errors.New("exit status 1")}
+	}
+	return calls, func() { qconf = orig }
+}
+
+var testClusterOutputs = map[string]string{
+	"-sc":                "#name shortcut type relop requestable consumable default urgency\nslots s INT <= YES YES 1 1000\n",
+	"-sul":               "arusers\n",
+	"-su arusers":        "name    arusers\ntype    ACL\nfshare  0\noticket 0\nentries alice\n",
+	"-sel":               "node1\n",
+	"-se global":         "hostname global\ncomplex_values NONE\nload_values NONE\n",
+	"-se node1":          "hostname node1\ncomplex_values slots=4\nload_values load_avg=0.1,\\\n mem_free=1G\nprocessors 4\n",
+	"-shgrpl":            "@all\n@sub\n",
+	"-shgrp @all":        "group_name @all\nhostlist @sub\n",
+	"-shgrp @sub":        "group_name @sub\nhostlist node1\n",
+	"-sconf global":      "#global:\nexecd_spool_dir /spool\nmailer /bin/mail\n",
+	"-ssconf":            testSchedulerConfig,
+	"-sql":               "all.q\n",
+	"-sq all.q":          "qname all.q\nhostlist @all\n",
+	"-srqsl":             "max_per_user\n",
+	"-srqs max_per_user": "{\n   name max_per_user\n   enabled TRUE\n   limit users {*} to slots=10\n}\n",
+}
+
+func TestBackupRestore(t *testing.T) {
+	_, restore := fakeCluster(testClusterOutputs)
+	defer restore()
+
+	dir, err := ioutil.TempDir("", "ugego_backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := Backup(dir); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s[KindQueues]) != 1 || len(s[KindHostGroups]) != 2 || len(s[KindExecHosts]) != 2 {
+		t.Errorf("Wrong snapshot: %v", s)
+	}
+	if len(s[KindProjects]) != 0 || len(s[KindShareTree]) != 0 {
+		t.Errorf("Objects which are not defined must not be in the snapshot: %v", s)
+	}
+	if node := s[KindExecHosts]["node1"]; strings.Contains(node, "load_values") || strings.Contains(node, "processors") {
+		t.Errorf("Load values must be removed:\n%s", node)
+	}
+	if conf := s[KindConfig]["global"]; strings.Contains(conf, "#global") {
+		t.Errorf("Comments must be removed:\n%s", conf)
+	}
+
+	// dry-run against the same cluster: all objects exist, hence modify
+	actions, err := Restore(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cmds []string
+	for _, a := range actions {
+		cmds = append(cmds, a.Args[0]+" "+a.Name)
+	}
+	expected := "-Mc global,-Mu arusers,-Me global,-Me node1,-Mhgrp @sub,-Mhgrp @all,-Mconf global,-Msconf global,-Mq all.q,-Mrqs max_per_user"
+	if got := strings.Join(cmds, ","); got != expected {
+		t.Errorf("Wrong restore actions:\n%s\nexpected\n%s", got, expected)
+	}
+
+	// restore into an empty cluster
+	calls, restoreEmpty := fakeCluster(map[string]string{})
+	defer restoreEmpty()
+	if _, err := Restore(dir, false); err == nil {
+		t.Error("Expected error since fake cluster fails all qconf calls")
+	}
+	if len(*calls) == 0 || (*calls)[len(*calls)-1] != "-Mc "+dir+"/complexes/global" {
+		t.Errorf("Wrong qconf calls: %v", *calls)
+	}
+}
+
+func TestHostGroupOrder(t *testing.T) {
+	order, err := hostGroupOrder(map[string]string{
+		"@a": "group_name @a\nhostlist @b @c",
+		"@b": "group_name @b\nhostlist @c",
+		"@c": "group_name @c\nhostlist node1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "@c,@b,@a" {
+		t.Errorf("Wrong host group order: %v", order)
+	}
+	if _, err := hostGroupOrder(map[string]string{
+		"@a": "group_name @a\nhostlist @b",
+		"@b": "group_name @b\nhostlist @a",
+	}); err == nil {
+		t.Error("Expected error for cyclic host groups")
+	}
+}
+
+func TestNotDefined(t *testing.T) {
+	for msg, expected := range map[string]bool{
+		"no project list defined": true,
+		"no sharetree element":    true,
+		"No cluster queue or queue instance matches the phrase \"x\"": true,
+		"project \"x\" does not exist":                                true,
+		"no permission to modify the configuration":                   false,
+		"no such host \"node9\"":                                      false,
+		"denied: host \"node1\" is neither submit nor admin host":     false,
+	} {
+		err := &QconfError{Message: msg, Err: errors.New("exit status 1")}
+		if notDefined(err) != expected {
+			t.Errorf("notDefined(%q) should be %t", msg, expected)
+		}
+	}
+	if notDefined(errors.New("no object defined")) {
+		t.Errorf("Only qconf errors can be not defined errors")
+	}
+}
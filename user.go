@@ -0,0 +1,137 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// User is a Univa Grid Engine user object as shown by qconf -suser.
+// A zero DeleteTime means that the user object is never deleted
+// automatically. An empty DefaultProject is shown as NONE by qconf.
+type User struct {
+	Name           string
+	OTicket        int
+	FShare         int
+	DeleteTime     time.Time
+	DefaultProject string
+}
+
+// ParseUser parses a user object in the format of qconf -suser:
+// name            daniel
+// oticket         0
+// fshare          0
+// delete_time     0
+// default_project NONE
+func ParseUser(user string) (*User, error) {
+	attrs, err := parseAttributes(user)
+	if err != nil {
+		return nil, err
+	}
+	var u User
+	for _, a := range attrs {
+		switch a.name {
+		case "name":
+			u.Name = a.value
+		case "oticket":
+			u.OTicket, err = strconv.Atoi(a.value)
+		case "fshare":
+			u.FShare, err = strconv.Atoi(a.value)
+		case "delete_time":
+			var seconds int64
+			if seconds, err = strconv.ParseInt(a.value, 10, 64); err == nil && seconds != 0 {
+				u.DeleteTime = time.Unix(seconds, 0)
+			}
+		case "default_project":
+			if a.value != "NONE" {
+				u.DefaultProject = a.value
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error during %s parsing: %s", a.name, err)
+		}
+	}
+	if u.Name == "" {
+		return nil, errors.New("user has no name")
+	}
+	return &u, nil
+}
+
+// String returns the user object in the qconf file format.
+func (u User) String() string {
+	var deleteTime int64
+	if !u.DeleteTime.IsZero() {
+		deleteTime = u.DeleteTime.Unix()
+	}
+	project := u.DefaultProject
+	if project == "" {
+		project = "NONE"
+	}
+	return formatAttributes([]attribute{
+		{"name", u.Name},
+		{"oticket", strconv.Itoa(u.OTicket)},
+		{"fshare", strconv.Itoa(u.FShare)},
+		{"delete_time", strconv.FormatInt(deleteTime, 10)},
+		{"default_project", project},
+	})
+}
+
+// GetUserNames calls qconf -suserl and returns the names of all user
+// objects.
+func GetUserNames() ([]string, error) {
+	return qconfList("-suserl")
+}
+
+// GetUsers calls qconf -suser <name> for each given user and parses
+// the output into User structs.
+func GetUsers(names ...string) ([]User, error) {
+	users := make([]User, 0, len(names))
+	for _, name := range names {
+		out, err := qconf("-suser", name)
+		if err != nil {
+			return nil, err
+		}
+		u, err := ParseUser(string(out))
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, nil
+}
+
+// AddUser creates the user object in the cluster (qconf -Auser).
+func AddUser(u User) error {
+	return qconfFromFile("-Auser", u.String())
+}
+
+// ModifyUser replaces the configuration of an existing user object
+// (qconf -Muser).
+func ModifyUser(u User) error {
+	return qconfFromFile("-Muser", u.String())
+}
+
+// DeleteUsers removes the given user objects from the cluster
+// (qconf -duser).
+func DeleteUsers(names ...string) error {
+	_, err := qconf("-duser", strings.Join(names, ","))
+	return err
+}
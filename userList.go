@@ -44,7 +44,8 @@ type UserList struct {
 // entries daniel,root,%wheel
 func ParseUserList(ul string) (userList *UserList, err error) {
 	var ol UserList
-	el := strings.Split(ul, "\n")
+	// tolerate the trailing newline of the qconf output (and String)
+	el := strings.Split(strings.TrimRight(ul, "\r\n"), "\n")
 	if len(el) != 5 {
 		return nil, errors.New(fmt.Sprintf("User list does not have 5 lines, it has %d.\n", len(el)))
 	}
@@ -122,3 +123,49 @@ func GetUserLists(userlist ...string) ([]UserList, error) {
 		return nil, errOut
 	}
 }
+
+// String returns the user list in the qconf file format.
+func (ul UserList) String() string {
+	return formatAttributes([]attribute{
+		{"name", ul.Name},
+		{"type", ul.Type},
+		{"fshare", strconv.Itoa(ul.FShare)},
+		{"oticket", strconv.Itoa(ul.OTicket)},
+		{"entries", joinList(ul.Entries)},
+	})
+}
+
+// GetUserListNames calls qconf -sul and returns the names of all
+// access control lists and departments.
+func GetUserListNames() ([]string, error) {
+	return qconfList("-sul")
+}
+
+// AddUserList creates the user list in the cluster (qconf -Au).
+func AddUserList(ul UserList) error {
+	return qconfFromFile("-Au", ul.String())
+}
+
+// ModifyUserList replaces the configuration of an existing user list
+// (qconf -Mu).
+func ModifyUserList(ul UserList) error {
+	return qconfFromFile("-Mu", ul.String())
+}
+
+// DeleteUserLists removes the given user lists from the cluster
+// (qconf -dul).
+func DeleteUserLists(names ...string) error {
+	_, err := qconf("-dul", strings.Join(names, ","))
+	return err
+}
+
+// Contains returns true if the user or its primary UNIX group (listed
+// as %group) is an entry of the user list.
+func (ul UserList) Contains(user, group string) bool {
+	for _, e := range ul.Entries {
+		if e == user || (group != "" && e == "%"+group) {
+			return true
+		}
+	}
+	return false
+}
@@ -17,6 +17,7 @@
 package ugego
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -76,3 +77,20 @@ func TestGetUserLists(t *testing.T) {
 		t.Errorf("Wrong name in deadlineusers list: %s", ul[0].Name)
 	}
 }
+
+func TestUserListString(t *testing.T) {
+	ul := UserList{Name: "dept1", Type: "DEPT", FShare: 10, Entries: []string{"daniel", "root"}}
+	if !strings.HasSuffix(ul.String(), "\n") {
+		t.Fatalf("Expected qconf format with trailing newline")
+	}
+	u, err := ParseUserList(ul.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "dept1" || u.Type != "DEPT" || u.FShare != 10 {
+		t.Errorf("Round trip failed: %v", u)
+	}
+	if len(u.Entries) != 2 || u.Entries[1] != "root" {
+		t.Errorf("Wrong entries after round trip: %v", u.Entries)
+	}
+}
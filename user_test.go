@@ -0,0 +1,60 @@
+/*
+   Copyright 2015 Daniel Gruber, dgruber@univa.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ugego
+
+import (
+	"testing"
+)
+
+func TestParseUser(t *testing.T) {
+	user := `name            daniel
+oticket         0
+fshare          42
+delete_time     1448376371
+default_project project1`
+	u, err := ParseUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "daniel" {
+		t.Errorf("Name is not daniel, it is %s", u.Name)
+	}
+	if u.FShare != 42 {
+		t.Errorf("FShare is not 42, it is %d", u.FShare)
+	}
+	if u.DeleteTime.Unix() != 1448376371 {
+		t.Errorf("Wrong delete time: %s", u.DeleteTime)
+	}
+	if u.DefaultProject != "project1" {
+		t.Errorf("Default project is not project1, it is %s", u.DefaultProject)
+	}
+	u2, err := ParseUser(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *u2 != *u {
+		t.Errorf("Round trip failed:\n%s\n%s", u, u2)
+	}
+
+	u, err = ParseUser("name root\noticket 0\nfshare 0\ndelete_time 0\ndefault_project NONE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.DeleteTime.IsZero() || u.DefaultProject != "" {
+		t.Errorf("Expected no delete time and default project: %v", u)
+	}
+}